@@ -0,0 +1,231 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+)
+
+// aclIDSeparator joins the fields of an ACL in its ID, principals contain ':'
+const aclIDSeparator = "|"
+
+// Values accepted by the ACL attributes, named as in sarama and kafka-acls.sh
+var (
+	aclResourceTypes   = []string{"Topic", "Group", "Cluster", "TransactionalID", "DelegationToken"}
+	aclPatternTypes    = []string{"Literal", "Prefixed"}
+	aclOperations      = []string{"All", "Read", "Write", "Create", "Delete", "Alter", "Describe", "ClusterAction", "DescribeConfigs", "AlterConfigs", "IdempotentWrite"}
+	aclPermissionTypes = []string{"Allow", "Deny"}
+)
+
+// validateAclValue builds the ValidateFunc of an ACL attribute
+func validateAclValue(values []string) func(interface{}, string) ([]string, []error) {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		value := v.(string)
+		for _, allowed := range values {
+			if value == allowed {
+				return
+			}
+		}
+
+		errors = append(errors, fmt.Errorf("%s must be one of %v, got %q", k, values, value))
+		return
+	}
+}
+
+// aclGetter is satisfied by schema.ResourceData and by plain blocks
+type aclGetter interface {
+	Get(string) interface{}
+}
+
+// aclBlock reads the attributes of an ACL from a nested block
+type aclBlock map[string]interface{}
+
+func (b aclBlock) Get(key string) interface{} {
+	return b[key]
+}
+
+// expandAcl reads an ACL from the attributes shared by the ACL resources
+func expandAcl(d aclGetter) (helper.Acl, error) {
+	return newAcl(
+		d.Get("resource_type").(string),
+		d.Get("resource_name").(string),
+		d.Get("resource_pattern_type").(string),
+		d.Get("principal").(string),
+		d.Get("host").(string),
+		d.Get("operation").(string),
+		d.Get("permission_type").(string),
+	)
+}
+
+func newAcl(resourceType, resourceName, patternType, principal, host, operation, permissionType string) (helper.Acl, error) {
+	acl := helper.Acl{
+		ResourceName: resourceName,
+		Principal:    principal,
+		Host:         host,
+	}
+
+	if err := acl.ResourceType.UnmarshalText([]byte(resourceType)); err != nil {
+		return acl, err
+	}
+
+	if err := acl.PatternType.UnmarshalText([]byte(patternType)); err != nil {
+		return acl, err
+	}
+
+	if err := acl.Operation.UnmarshalText([]byte(operation)); err != nil {
+		return acl, err
+	}
+
+	if err := acl.PermissionType.UnmarshalText([]byte(permissionType)); err != nil {
+		return acl, err
+	}
+
+	return acl, nil
+}
+
+// flattenAcl returns the attributes of an ACL
+func flattenAcl(acl helper.Acl) map[string]interface{} {
+	return map[string]interface{}{
+		"resource_type":         acl.ResourceType.String(),
+		"resource_name":         acl.ResourceName,
+		"resource_pattern_type": acl.PatternType.String(),
+		"principal":             acl.Principal,
+		"host":                  acl.Host,
+		"operation":             acl.Operation.String(),
+		"permission_type":       acl.PermissionType.String(),
+	}
+}
+
+// aclID identifies an ACL by all of its fields, the resource name comes last
+// since group and transactional id names may contain the separator
+func aclID(acl helper.Acl) string {
+	return strings.Join([]string{
+		acl.ResourceType.String(),
+		acl.PatternType.String(),
+		acl.Principal,
+		acl.Host,
+		acl.Operation.String(),
+		acl.PermissionType.String(),
+		acl.ResourceName,
+	}, aclIDSeparator)
+}
+
+// parseAclID reverses aclID
+func parseAclID(id string) (helper.Acl, error) {
+	parts := strings.SplitN(id, aclIDSeparator, 7)
+	if len(parts) != 7 {
+		return helper.Acl{}, fmt.Errorf(
+			"ACL ID %q must be resource_type|resource_pattern_type|principal|host|operation|permission_type|resource_name", id,
+		)
+	}
+
+	return newAcl(parts[0], parts[6], parts[1], parts[2], parts[3], parts[4], parts[5])
+}
+
+// sortAcls orders ACLs by ID so that plans and errors are stable
+func sortAcls(acls []helper.Acl) {
+	sort.Slice(acls, func(i, j int) bool { return aclID(acls[i]) < aclID(acls[j]) })
+}
+
+// createAcls creates the ACLs in a single request
+func createAcls(broker *adminBroker, acls []helper.Acl) error {
+	if len(acls) == 0 {
+		return nil
+	}
+
+	response, err := broker.CreateAcls(r.CreateKafkaAclsRequest(acls))
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	var failures []string
+	for i, creation := range response.AclCreationResponses {
+		if creation.Err == sarama.ErrNoError || i >= len(acls) {
+			continue
+		}
+
+		failures = append(failures, fmt.Sprintf("  %s: %s", aclID(acls[i]), kafkaError(creation.Err, creation.ErrMsg)))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("Error creating ACLs:\n%s", strings.Join(failures, "\n"))
+	}
+
+	return nil
+}
+
+// describeAcls lists the ACLs matching the filter
+func describeAcls(broker *adminBroker, filter *sarama.AclFilter) ([]helper.Acl, error) {
+	response, err := broker.DescribeAcls(r.DescribeKafkaAclsRequest(filter))
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	if response.Err != sarama.ErrNoError {
+		return nil, kafkaError(response.Err, response.ErrMsg)
+	}
+
+	acls := helper.ResourceAclsToAcls(response.ResourceAcls)
+	sortAcls(acls)
+
+	return acls, nil
+}
+
+// aclExists looks an ACL up on the cluster
+func aclExists(broker *adminBroker, acl helper.Acl) (bool, error) {
+	acls, err := describeAcls(broker, acl.Filter())
+	if err != nil {
+		return false, err
+	}
+
+	for _, existing := range acls {
+		if existing == acl {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// deleteAcls deletes exactly the given ACLs in a single request
+func deleteAcls(broker *adminBroker, acls []helper.Acl) error {
+	if len(acls) == 0 {
+		return nil
+	}
+
+	response, err := broker.DeleteAcls(r.DeleteKafkaAclsRequest(acls))
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	var failures []string
+	for i, filter := range response.FilterResponses {
+		if i >= len(acls) {
+			break
+		}
+
+		if filter.Err != sarama.ErrNoError {
+			failures = append(failures, fmt.Sprintf("  %s: %s", aclID(acls[i]), kafkaError(filter.Err, filter.ErrMsg)))
+			continue
+		}
+
+		for _, matching := range filter.MatchingAcls {
+			if matching.Err != sarama.ErrNoError {
+				failures = append(failures, fmt.Sprintf("  %s: %s", aclID(acls[i]), kafkaError(matching.Err, matching.ErrMsg)))
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("Error deleting ACLs:\n%s", strings.Join(failures, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+var groupAcl = helper.Acl{
+	ResourceType:   sarama.AclResourceGroup,
+	ResourceName:   "billing|consumers",
+	PatternType:    sarama.AclPatternLiteral,
+	Principal:      "User:CN=billing,O=example",
+	Host:           "*",
+	Operation:      sarama.AclOperationRead,
+	PermissionType: sarama.AclPermissionAllow,
+}
+
+func TestAclID(t *testing.T) {
+	id := aclID(groupAcl)
+	assert.Equal(t, "Group|Literal|User:CN=billing,O=example|*|Read|Allow|billing|consumers", id)
+
+	acl, err := parseAclID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, groupAcl, acl)
+
+	// values are case insensitive on import
+	acl, err = parseAclID("group|literal|User:CN=billing,O=example|*|read|allow|billing|consumers")
+	assert.NoError(t, err)
+	assert.Equal(t, groupAcl, acl)
+}
+
+func TestParseAclIDErrors(t *testing.T) {
+	_, err := parseAclID("Topic|Literal|User:alice")
+	assert.Error(t, err)
+
+	_, err = parseAclID("Table|Literal|User:alice|*|Read|Allow|orders")
+	assert.Error(t, err)
+}
+
+func TestValidateAclValue(t *testing.T) {
+	validate := validateAclValue(aclOperations)
+
+	_, errs := validate("Read", "operation")
+	assert.Empty(t, errs)
+
+	_, errs = validate("read", "operation")
+	assert.Len(t, errs, 1)
+
+	_, errs = validate("Any", "operation")
+	assert.Len(t, errs, 1)
+}
+
+func TestExpandAndFlattenAcl(t *testing.T) {
+	block := flattenAcl(groupAcl)
+	assert.Equal(t, "Group", block["resource_type"])
+	assert.Equal(t, "Literal", block["resource_pattern_type"])
+	assert.Equal(t, "Read", block["operation"])
+	assert.Equal(t, "Allow", block["permission_type"])
+
+	acl, err := expandAcl(aclBlock(block))
+	assert.NoError(t, err)
+	assert.Equal(t, groupAcl, acl)
+}
+
+func TestSortAcls(t *testing.T) {
+	write := groupAcl
+	write.ResourceType = sarama.AclResourceTopic
+	acls := []helper.Acl{write, groupAcl}
+
+	sortAcls(acls)
+	assert.Equal(t, []helper.Acl{groupAcl, write}, acls)
+}
+
+func TestKafkaAclSchema(t *testing.T) {
+	for key, s := range kafkaAclSchema() {
+		assert.True(t, s.ForceNew, key)
+	}
+}
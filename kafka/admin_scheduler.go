@@ -0,0 +1,351 @@
+package kafka
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// defaultMaxConcurrentAdminRequests is used when the provider does not set
+// max_concurrent_admin_requests
+const defaultMaxConcurrentAdminRequests = 5
+
+// adminCoalesceWindow is how long a CreateTopics request waits for others to
+// join it in a single batched request
+const adminCoalesceWindow = 20 * time.Millisecond
+
+// adminScheduler bounds the number of admin requests in flight across every
+// resource of the run and coalesces concurrent CreateTopics requests sent to
+// the same broker.
+type adminScheduler struct {
+	slots chan struct{}
+
+	mu      sync.Mutex
+	pending map[*sarama.Broker]*pendingCreateTopics
+}
+
+// pendingCreateTopics is a CreateTopics request still open to other topics
+type pendingCreateTopics struct {
+	request  *sarama.CreateTopicsRequest
+	done     chan struct{}
+	response *sarama.CreateTopicsResponse
+	err      error
+}
+
+func newAdminScheduler(maxConcurrent int) *adminScheduler {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+
+	return &adminScheduler{
+		slots:   make(chan struct{}, maxConcurrent),
+		pending: make(map[*sarama.Broker]*pendingCreateTopics),
+	}
+}
+
+// broker routes the admin requests sent to a broker through the scheduler
+func (s *adminScheduler) broker(broker *sarama.Broker) *adminBroker {
+	return &adminBroker{broker: broker, scheduler: s}
+}
+
+// do runs a request once a slot is available
+func (s *adminScheduler) do(request func() error) error {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	return request()
+}
+
+// createTopics joins the pending CreateTopics request to the broker when
+// possible, or opens a new one which is sent after adminCoalesceWindow
+func (s *adminScheduler) createTopics(broker *sarama.Broker, request *sarama.CreateTopicsRequest) (*sarama.CreateTopicsResponse, error) {
+	s.mu.Lock()
+	pending, ok := s.pending[broker]
+	if ok && joinCreateTopics(pending.request, request) {
+		s.mu.Unlock()
+		<-pending.done
+		return splitCreateTopicsResponse(pending.response, request), pending.err
+	}
+
+	if ok {
+		// incompatible with the pending request, sent on its own
+		s.mu.Unlock()
+		var response *sarama.CreateTopicsResponse
+		err := s.do(func() (err error) {
+			response, err = broker.CreateTopics(request)
+			return
+		})
+		return response, err
+	}
+
+	pending = &pendingCreateTopics{
+		request: &sarama.CreateTopicsRequest{
+			Version:      request.Version,
+			Timeout:      request.Timeout,
+			ValidateOnly: request.ValidateOnly,
+			TopicDetails: make(map[string]*sarama.TopicDetail, len(request.TopicDetails)),
+		},
+		done: make(chan struct{}),
+	}
+	for topic, detail := range request.TopicDetails {
+		pending.request.TopicDetails[topic] = detail
+	}
+	s.pending[broker] = pending
+	s.mu.Unlock()
+
+	time.Sleep(adminCoalesceWindow)
+
+	s.mu.Lock()
+	delete(s.pending, broker)
+	s.mu.Unlock()
+
+	pending.err = s.do(func() (err error) {
+		pending.response, err = broker.CreateTopics(pending.request)
+		return
+	})
+	close(pending.done)
+
+	return splitCreateTopicsResponse(pending.response, request), pending.err
+}
+
+// joinCreateTopics adds the topics of request to pending when both requests
+// can be sent as one
+func joinCreateTopics(pending *sarama.CreateTopicsRequest, request *sarama.CreateTopicsRequest) bool {
+	if pending.Version != request.Version ||
+		pending.Timeout != request.Timeout ||
+		pending.ValidateOnly != request.ValidateOnly ||
+		len(pending.TopicDetails)+len(request.TopicDetails) > topicBatchSize {
+		return false
+	}
+
+	for topic := range request.TopicDetails {
+		if _, ok := pending.TopicDetails[topic]; ok {
+			return false
+		}
+	}
+
+	for topic, detail := range request.TopicDetails {
+		pending.TopicDetails[topic] = detail
+	}
+
+	return true
+}
+
+// splitCreateTopicsResponse keeps the results of the topics of request
+func splitCreateTopicsResponse(response *sarama.CreateTopicsResponse, request *sarama.CreateTopicsRequest) *sarama.CreateTopicsResponse {
+	if response == nil {
+		return nil
+	}
+
+	split := &sarama.CreateTopicsResponse{
+		Version:      response.Version,
+		ThrottleTime: response.ThrottleTime,
+		TopicErrors:  make(map[string]*sarama.TopicError, len(request.TopicDetails)),
+	}
+
+	for topic := range request.TopicDetails {
+		if topicErr, ok := response.TopicErrors[topic]; ok {
+			split.TopicErrors[topic] = topicErr
+		}
+	}
+
+	return split
+}
+
+// adminBroker sends the admin requests of the provider through the
+// scheduler. The underlying broker is not exposed, a request without a
+// wrapper below cannot be sent around the scheduler.
+type adminBroker struct {
+	broker    *sarama.Broker
+	scheduler *adminScheduler
+}
+
+// peer routes the requests sent to another broker of the cluster through the
+// same scheduler
+func (b *adminBroker) peer(broker *sarama.Broker) *adminBroker {
+	return b.scheduler.broker(broker)
+}
+
+// ID returns the ID of the underlying broker
+func (b *adminBroker) ID() int32 {
+	return b.broker.ID()
+}
+
+// Close closes the connection to the underlying broker
+func (b *adminBroker) Close() error {
+	return b.broker.Close()
+}
+
+// withClient runs use with a client of its own, for consumers and producers
+// which need the whole cluster. The client holds a slot of the scheduler
+// until it is closed.
+func (b *adminBroker) withClient(addrs []string, config *sarama.Config, use func(sarama.Client) error) error {
+	return b.scheduler.do(func() error {
+		client, err := sarama.NewClient(addrs, config)
+		if err != nil {
+			log.Println(err.Error())
+			return err
+		}
+		defer client.Close()
+
+		return use(client)
+	})
+}
+
+func (b *adminBroker) CreateTopics(request *sarama.CreateTopicsRequest) (*sarama.CreateTopicsResponse, error) {
+	return b.scheduler.createTopics(b.broker, request)
+}
+
+func (b *adminBroker) GetMetadata(request *sarama.MetadataRequest) (response *sarama.MetadataResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.GetMetadata(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) ApiVersions(request *sarama.ApiVersionsRequest) (response *sarama.ApiVersionsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.ApiVersions(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) DescribeConfigs(request *sarama.DescribeConfigsRequest) (response *sarama.DescribeConfigsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.DescribeConfigs(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) AlterConfigs(request *sarama.AlterConfigsRequest) (response *sarama.AlterConfigsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.AlterConfigs(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) IncrementalAlterConfigs(request *sarama.IncrementalAlterConfigsRequest) (response *sarama.IncrementalAlterConfigsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.IncrementalAlterConfigs(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) DeleteTopics(request *sarama.DeleteTopicsRequest) (response *sarama.DeleteTopicsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.DeleteTopics(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) CreatePartitions(request *sarama.CreatePartitionsRequest) (response *sarama.CreatePartitionsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.CreatePartitions(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) GetAvailableOffsets(request *sarama.OffsetRequest) (response *sarama.OffsetResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.GetAvailableOffsets(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) ListGroups(request *sarama.ListGroupsRequest) (response *sarama.ListGroupsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.ListGroups(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) FindCoordinator(request *sarama.FindCoordinatorRequest) (response *sarama.FindCoordinatorResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.FindCoordinator(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) DescribeGroups(request *sarama.DescribeGroupsRequest) (response *sarama.DescribeGroupsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.DescribeGroups(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) FetchOffset(request *sarama.OffsetFetchRequest) (response *sarama.OffsetFetchResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.FetchOffset(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) DescribeClientQuotas(request *sarama.DescribeClientQuotasRequest) (response *sarama.DescribeClientQuotasResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.DescribeClientQuotas(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) AlterClientQuotas(request *sarama.AlterClientQuotasRequest) (response *sarama.AlterClientQuotasResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.AlterClientQuotas(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) DescribeUserScramCredentials(request *sarama.DescribeUserScramCredentialsRequest) (response *sarama.DescribeUserScramCredentialsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.DescribeUserScramCredentials(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) AlterUserScramCredentials(request *sarama.AlterUserScramCredentialsRequest) (response *sarama.AlterUserScramCredentialsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.AlterUserScramCredentials(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) CreateAcls(request *sarama.CreateAclsRequest) (response *sarama.CreateAclsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.CreateAcls(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) DescribeAcls(request *sarama.DescribeAclsRequest) (response *sarama.DescribeAclsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.DescribeAcls(request)
+		return err
+	})
+	return
+}
+
+func (b *adminBroker) DeleteAcls(request *sarama.DeleteAclsRequest) (response *sarama.DeleteAclsResponse, err error) {
+	err = b.scheduler.do(func() error {
+		response, err = b.broker.DeleteAcls(request)
+		return err
+	})
+	return
+}
@@ -0,0 +1,146 @@
+package kafka
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminSchedulerBoundsConcurrency(t *testing.T) {
+	scheduler := newAdminScheduler(2)
+
+	var mu sync.Mutex
+	var running, peak int
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scheduler.do(func() error {
+				mu.Lock()
+				running++
+				if running > peak {
+					peak = running
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, 2, peak)
+}
+
+func TestAdminBrokerClientHoldsSlot(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(mock.Addr(), mock.BrokerID()),
+	})
+
+	scheduler := newAdminScheduler(1)
+	broker := scheduler.broker(sarama.NewBroker(mock.Addr()))
+
+	config := brokerConfig()
+	config.Version = sarama.V0_11_0_0
+	err := broker.withClient([]string{mock.Addr()}, config, func(client sarama.Client) error {
+		assert.Len(t, scheduler.slots, 1)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, scheduler.slots)
+}
+
+func TestJoinCreateTopics(t *testing.T) {
+	pending := &sarama.CreateTopicsRequest{
+		Version:      1,
+		TopicDetails: map[string]*sarama.TopicDetail{"a": {}},
+	}
+
+	assert.True(t, joinCreateTopics(pending, &sarama.CreateTopicsRequest{
+		Version:      1,
+		TopicDetails: map[string]*sarama.TopicDetail{"b": {}},
+	}))
+	assert.Len(t, pending.TopicDetails, 2)
+
+	// same topic twice
+	assert.False(t, joinCreateTopics(pending, &sarama.CreateTopicsRequest{
+		Version:      1,
+		TopicDetails: map[string]*sarama.TopicDetail{"a": {}},
+	}))
+
+	// different version
+	assert.False(t, joinCreateTopics(pending, &sarama.CreateTopicsRequest{
+		Version:      0,
+		TopicDetails: map[string]*sarama.TopicDetail{"c": {}},
+	}))
+	assert.Len(t, pending.TopicDetails, 2)
+}
+
+func TestSplitCreateTopicsResponse(t *testing.T) {
+	response := &sarama.CreateTopicsResponse{
+		Version: 1,
+		TopicErrors: map[string]*sarama.TopicError{
+			"a": {Err: sarama.ErrNoError},
+			"b": {Err: sarama.ErrTopicAlreadyExists},
+		},
+	}
+
+	split := splitCreateTopicsResponse(response, &sarama.CreateTopicsRequest{
+		TopicDetails: map[string]*sarama.TopicDetail{"b": {}},
+	})
+	assert.Len(t, split.TopicErrors, 1)
+	assert.Equal(t, sarama.ErrTopicAlreadyExists, split.TopicErrors["b"].Err)
+
+	assert.Nil(t, splitCreateTopicsResponse(nil, &sarama.CreateTopicsRequest{}))
+}
+
+func TestAdminSchedulerCoalescesCreateTopics(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"CreateTopicsRequest": sarama.NewMockCreateTopicsResponse(t),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	admin := newAdminScheduler(1).broker(broker)
+
+	var wg sync.WaitGroup
+	responses := make([]*sarama.CreateTopicsResponse, 3)
+	for i, topic := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(i int, topic string) {
+			defer wg.Done()
+			response, err := admin.CreateTopics(&sarama.CreateTopicsRequest{
+				Version:      1,
+				TopicDetails: map[string]*sarama.TopicDetail{topic: {NumPartitions: 1, ReplicationFactor: 1}},
+			})
+			assert.NoError(t, err)
+			responses[i] = response
+		}(i, topic)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, requestCounts(mock)["*sarama.CreateTopicsRequest"])
+	for i, topic := range []string{"a", "b", "c"} {
+		assert.Len(t, responses[i].TopicErrors, 1)
+		assert.Equal(t, sarama.ErrNoError, responses[i].TopicErrors[topic].Err)
+	}
+}
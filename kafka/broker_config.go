@@ -0,0 +1,147 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/IBM/sarama"
+)
+
+// brokerConfigDefaultID is the ID of the cluster-wide defaults, Kafka names
+// them with an empty broker name
+const brokerConfigDefaultID = "default"
+
+func validateBrokerID(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	if value == "" {
+		return
+	}
+
+	if id, err := strconv.Atoi(value); err != nil || id < 0 {
+		errors = append(errors, fmt.Errorf("%s must be a broker id or empty for the cluster defaults, got %q", k, value))
+	}
+	return
+}
+
+// brokerConfigID identifies the configs of a broker, or the cluster defaults
+func brokerConfigID(brokerID string) string {
+	if brokerID == "" {
+		return brokerConfigDefaultID
+	}
+
+	return brokerID
+}
+
+// parseBrokerConfigID reverses brokerConfigID
+func parseBrokerConfigID(id string) (string, error) {
+	if id == brokerConfigDefaultID {
+		return "", nil
+	}
+
+	if _, errs := validateBrokerID(id, "ID"); len(errs) > 0 {
+		return "", errs[0]
+	}
+
+	return id, nil
+}
+
+// clusterBrokers lists the brokers of the cluster from metadata
+func clusterBrokers(broker *adminBroker) ([]*sarama.Broker, error) {
+	metadata, err := broker.GetMetadata(r.GetKafkaAllTopicsMetadataRequest())
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	return metadata.Brokers, nil
+}
+
+// routeToPeer opens a broker discovered through metadata, the returned
+// function closes the connection
+func routeToPeer(broker *adminBroker, b *sarama.Broker) (*adminBroker, func(), error) {
+	if err := openBroker(b); err != nil {
+		return nil, nil, err
+	}
+
+	return broker.peer(b), func() { b.Close() }, nil
+}
+
+// routeToBroker returns the broker a config request of brokerID must be sent
+// to: brokers only describe and alter their own configs while the cluster
+// defaults are served by any broker. The returned function closes the
+// connection opened for the request.
+func routeToBroker(broker *adminBroker, brokerID string) (*adminBroker, func(), error) {
+	if brokerID == "" {
+		return broker, func() {}, nil
+	}
+
+	brokers, err := clusterBrokers(broker)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, b := range brokers {
+		if strconv.Itoa(int(b.ID())) == brokerID {
+			return routeToPeer(broker, b)
+		}
+	}
+
+	return nil, nil, fmt.Errorf("Broker %s is not part of the cluster", brokerID)
+}
+
+// describeResourceConfigs returns the config entries of a resource, all of
+// them when configNames is empty
+func describeResourceConfigs(broker *adminBroker, resourceType sarama.ConfigResourceType, name string, configNames []string) ([]*sarama.ConfigEntry, error) {
+	response, err := broker.DescribeConfigs(r.GetKafkaResourceConfigsRequest(resourceType, name, configNames))
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	var entries []*sarama.ConfigEntry
+	for _, resource := range response.Resources {
+		if resource.ErrorCode != 0 {
+			return nil, kafkaError(sarama.KError(resource.ErrorCode), &resource.ErrorMsg)
+		}
+
+		entries = append(entries, resource.Configs...)
+	}
+
+	return entries, nil
+}
+
+// alterResourceConfigs replaces the dynamic configs of a resource
+func alterResourceConfigs(broker *adminBroker, resourceType sarama.ConfigResourceType, name string, configs map[string]interface{}) error {
+	response, err := broker.AlterConfigs(r.AlterResourceConfigsRequest(resourceType, name, configs))
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	for _, resource := range response.Resources {
+		if resource.ErrorCode != 0 {
+			return kafkaError(sarama.KError(resource.ErrorCode), &resource.ErrorMsg)
+		}
+	}
+
+	return nil
+}
+
+// setResourceConfigs sets some entries of a resource, the others are kept and
+// nil values fall back to their default
+func setResourceConfigs(broker *adminBroker, resourceType sarama.ConfigResourceType, name string, configs map[string]*string) error {
+	response, err := broker.IncrementalAlterConfigs(r.IncrementalAlterResourceConfigsRequest(resourceType, name, configs))
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	for _, resource := range response.Resources {
+		if resource.ErrorCode != 0 {
+			return kafkaError(sarama.KError(resource.ErrorCode), &resource.ErrorMsg)
+		}
+	}
+
+	return nil
+}
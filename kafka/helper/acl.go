@@ -0,0 +1,102 @@
+package helper
+
+import (
+	"github.com/IBM/sarama"
+)
+
+// aclRequestVersion is the first version of the ACL requests supporting
+// prefixed resource patterns
+const aclRequestVersion = 1
+
+// Acl is a single binding of an operation on a resource to a principal
+type Acl struct {
+	ResourceType   sarama.AclResourceType
+	ResourceName   string
+	PatternType    sarama.AclResourcePatternType
+	Principal      string
+	Host           string
+	Operation      sarama.AclOperation
+	PermissionType sarama.AclPermissionType
+}
+
+// Filter returns the sarama.AclFilter matching exactly this ACL
+func (a Acl) Filter() *sarama.AclFilter {
+	resourceName, principal, host := a.ResourceName, a.Principal, a.Host
+
+	return &sarama.AclFilter{
+		Version:                   aclRequestVersion,
+		ResourceType:              a.ResourceType,
+		ResourceName:              &resourceName,
+		ResourcePatternTypeFilter: a.PatternType,
+		Principal:                 &principal,
+		Host:                      &host,
+		Operation:                 a.Operation,
+		PermissionType:            a.PermissionType,
+	}
+}
+
+// CreateKafkaAclsRequest prepares a single sarama.CreateAclsRequest for several ACLs
+func (*ResourceHelper) CreateKafkaAclsRequest(acls []Acl) *sarama.CreateAclsRequest {
+	creations := make([]*sarama.AclCreation, 0, len(acls))
+	for _, acl := range acls {
+		creations = append(creations, &sarama.AclCreation{
+			Resource: sarama.Resource{
+				ResourceType:        acl.ResourceType,
+				ResourceName:        acl.ResourceName,
+				ResourcePatternType: acl.PatternType,
+			},
+			Acl: sarama.Acl{
+				Principal:      acl.Principal,
+				Host:           acl.Host,
+				Operation:      acl.Operation,
+				PermissionType: acl.PermissionType,
+			},
+		})
+	}
+
+	return &sarama.CreateAclsRequest{
+		Version:      aclRequestVersion,
+		AclCreations: creations,
+	}
+}
+
+// DescribeKafkaAclsRequest prepares sarama.DescribeAclsRequest for the ACLs matching filter
+func (*ResourceHelper) DescribeKafkaAclsRequest(filter *sarama.AclFilter) *sarama.DescribeAclsRequest {
+	return &sarama.DescribeAclsRequest{
+		Version:   aclRequestVersion,
+		AclFilter: *filter,
+	}
+}
+
+// DeleteKafkaAclsRequest prepares a single sarama.DeleteAclsRequest removing exactly the given ACLs
+func (*ResourceHelper) DeleteKafkaAclsRequest(acls []Acl) *sarama.DeleteAclsRequest {
+	filters := make([]*sarama.AclFilter, 0, len(acls))
+	for _, acl := range acls {
+		filters = append(filters, acl.Filter())
+	}
+
+	return &sarama.DeleteAclsRequest{
+		Version: aclRequestVersion,
+		Filters: filters,
+	}
+}
+
+// ResourceAclsToAcls flattens the ACLs of a sarama.DescribeAclsResponse
+func ResourceAclsToAcls(resources []*sarama.ResourceAcls) []Acl {
+	var acls []Acl
+	for _, resource := range resources {
+		for _, acl := range resource.Acls {
+			acls = append(acls, Acl{
+				ResourceType:   resource.ResourceType,
+				ResourceName:   resource.ResourceName,
+				PatternType:    resource.ResourcePatternType,
+				Principal:      acl.Principal,
+				Host:           acl.Host,
+				Operation:      acl.Operation,
+				PermissionType: acl.PermissionType,
+			})
+		}
+	}
+
+	return acls
+}
@@ -0,0 +1,59 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+var readAcl = Acl{
+	ResourceType:   sarama.AclResourceTopic,
+	ResourceName:   "orders",
+	PatternType:    sarama.AclPatternPrefixed,
+	Principal:      "User:alice",
+	Host:           "*",
+	Operation:      sarama.AclOperationRead,
+	PermissionType: sarama.AclPermissionAllow,
+}
+
+func TestAclFilter(t *testing.T) {
+	filter := readAcl.Filter()
+	assert.Equal(t, 1, filter.Version)
+	assert.Equal(t, sarama.AclResourceTopic, filter.ResourceType)
+	assert.Equal(t, "orders", *filter.ResourceName)
+	assert.Equal(t, sarama.AclPatternPrefixed, filter.ResourcePatternTypeFilter)
+	assert.Equal(t, "User:alice", *filter.Principal)
+	assert.Equal(t, "*", *filter.Host)
+	assert.Equal(t, sarama.AclOperationRead, filter.Operation)
+	assert.Equal(t, sarama.AclPermissionAllow, filter.PermissionType)
+}
+
+func TestCreateKafkaAclsRequest(t *testing.T) {
+	request := helper.CreateKafkaAclsRequest([]Acl{readAcl})
+	assert.Equal(t, int16(1), request.Version)
+	assert.Len(t, request.AclCreations, 1)
+	assert.Equal(t, "orders", request.AclCreations[0].ResourceName)
+	assert.Equal(t, sarama.AclPatternPrefixed, request.AclCreations[0].ResourcePatternType)
+	assert.Equal(t, "User:alice", request.AclCreations[0].Principal)
+}
+
+func TestDeleteKafkaAclsRequest(t *testing.T) {
+	request := helper.DeleteKafkaAclsRequest([]Acl{readAcl})
+	assert.Equal(t, 1, request.Version)
+	assert.Len(t, request.Filters, 1)
+	assert.Equal(t, "orders", *request.Filters[0].ResourceName)
+}
+
+func TestResourceAclsToAcls(t *testing.T) {
+	acls := ResourceAclsToAcls([]*sarama.ResourceAcls{
+		{
+			Resource: sarama.Resource{ResourceType: sarama.AclResourceTopic, ResourceName: "orders", ResourcePatternType: sarama.AclPatternPrefixed},
+			Acls: []*sarama.Acl{
+				{Principal: "User:alice", Host: "*", Operation: sarama.AclOperationRead, PermissionType: sarama.AclPermissionAllow},
+			},
+		},
+	})
+
+	assert.Equal(t, []Acl{readAcl}, acls)
+}
@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"sort"
+
+	"github.com/IBM/sarama"
+)
+
+// DescribeKafkaClientQuotasRequest prepares sarama.DescribeClientQuotasRequest
+// matching exactly the given entity, default components match the default
+// entity of their type
+func (*ResourceHelper) DescribeKafkaClientQuotasRequest(entity []sarama.QuotaEntityComponent) *sarama.DescribeClientQuotasRequest {
+	components := make([]sarama.QuotaFilterComponent, 0, len(entity))
+	for _, component := range entity {
+		components = append(components, sarama.QuotaFilterComponent{
+			EntityType: component.EntityType,
+			MatchType:  component.MatchType,
+			Match:      component.Name,
+		})
+	}
+
+	return &sarama.DescribeClientQuotasRequest{
+		Components: components,
+		Strict:     true,
+	}
+}
+
+// AlterKafkaClientQuotasRequest prepares sarama.AlterClientQuotasRequest
+// setting values and removing the removed keys of an entity
+func (*ResourceHelper) AlterKafkaClientQuotasRequest(entity []sarama.QuotaEntityComponent, values map[string]float64, removed []string) *sarama.AlterClientQuotasRequest {
+	ops := make([]sarama.ClientQuotasOp, 0, len(values)+len(removed))
+	for key, value := range values {
+		ops = append(ops, sarama.ClientQuotasOp{Key: key, Value: value})
+	}
+	for _, key := range removed {
+		ops = append(ops, sarama.ClientQuotasOp{Key: key, Remove: true})
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Key < ops[j].Key })
+
+	return &sarama.AlterClientQuotasRequest{
+		Entries: []sarama.AlterClientQuotasEntry{
+			{
+				Entity: entity,
+				Ops:    ops,
+			},
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+var appEntity = []sarama.QuotaEntityComponent{
+	{EntityType: sarama.QuotaEntityUser, MatchType: sarama.QuotaMatchExact, Name: "alice"},
+	{EntityType: sarama.QuotaEntityClientID, MatchType: sarama.QuotaMatchDefault},
+}
+
+func TestDescribeKafkaClientQuotasRequest(t *testing.T) {
+	res := helper.DescribeKafkaClientQuotasRequest(appEntity)
+	assert.True(t, res.Strict)
+	assert.Equal(t, []sarama.QuotaFilterComponent{
+		{EntityType: sarama.QuotaEntityUser, MatchType: sarama.QuotaMatchExact, Match: "alice"},
+		{EntityType: sarama.QuotaEntityClientID, MatchType: sarama.QuotaMatchDefault},
+	}, res.Components)
+}
+
+func TestAlterKafkaClientQuotasRequest(t *testing.T) {
+	res := helper.AlterKafkaClientQuotasRequest(appEntity, map[string]float64{"producer_byte_rate": 1024}, []string{"consumer_byte_rate"})
+	assert.Len(t, res.Entries, 1)
+	assert.Equal(t, appEntity, res.Entries[0].Entity)
+	assert.Equal(t, []sarama.ClientQuotasOp{
+		{Key: "consumer_byte_rate", Remove: true},
+		{Key: "producer_byte_rate", Value: 1024},
+	}, res.Entries[0].Ops)
+}
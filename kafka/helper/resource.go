@@ -3,9 +3,10 @@ package helper
 import (
 	"errors"
 	"log"
+	"strconv"
 	"time"
 
-	"github.com/Shopify/sarama"
+	"github.com/IBM/sarama"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -17,6 +18,7 @@ type Topic struct {
 	Partitions        int
 	ReplicationFactor int
 	ConfigEntries     map[string]*string
+	ReplicaAssignment map[int32][]int32
 }
 
 func (*ResourceHelper) ValidConfigNames() []string {
@@ -72,31 +74,53 @@ func (*ResourceHelper) CreateResourceParams(d *schema.ResourceData) (Topic, erro
 		Partitions:        partitions,
 		ReplicationFactor: replicationFactor,
 		ConfigEntries:     configEntries,
+		ReplicaAssignment: ReplicaAssignment(d.Get("replica_assignment").([]interface{})),
 	}
 	log.Printf("Topic: %#v", topic)
 	// TBD : Perform some validations if required
 	return topic, nil
 }
 
+// ReplicaAssignment parses the replica_assignment blocks into replicas by partition
+func ReplicaAssignment(blocks []interface{}) map[int32][]int32 {
+	replicaAssignment := make(map[int32][]int32, len(blocks))
+	for _, block := range blocks {
+		entry := block.(map[string]interface{})
+		replicas := make([]int32, 0)
+		for _, replica := range entry["replicas"].([]interface{}) {
+			replicas = append(replicas, int32(replica.(int)))
+		}
+		replicaAssignment[int32(entry["partition"].(int))] = replicas
+	}
+
+	return replicaAssignment
+}
+
 // CreateKafkaTopicRequest prepares sarama.CreateTopicsRequest from arguments
 func (*ResourceHelper) CreateKafkaTopicRequest(
 	name string,
 	partitions int,
 	replicationFactor int,
 	configEntries map[string]*string,
+	replicaAssignment map[int32][]int32,
 ) *sarama.CreateTopicsRequest {
-	// TODO: define replica assignment on input schema
-	var replicaAssignment map[int32][]int32
 	topicDetail := &sarama.TopicDetail{
 		NumPartitions:     int32(partitions),
 		ReplicationFactor: int16(replicationFactor),
-		ReplicaAssignment: replicaAssignment,
 		ConfigEntries:     configEntries,
 	}
+	// an explicit assignment replaces the partition count and replication factor
+	if len(replicaAssignment) > 0 {
+		topicDetail.NumPartitions = -1
+		topicDetail.ReplicationFactor = -1
+		topicDetail.ReplicaAssignment = replicaAssignment
+	}
 	topicDetails := make(map[string]*sarama.TopicDetail)
 	topicDetails[name] = topicDetail
 
 	return &sarama.CreateTopicsRequest{
+		// version 1 reports an error message, needed to spot topics pending deletion
+		Version:      1,
 		Timeout:      time.Second * 15,
 		TopicDetails: topicDetails,
 	}
@@ -105,15 +129,20 @@ func (*ResourceHelper) CreateKafkaTopicRequest(
 // DeleteKafkaTopicRequest prepares sarama.DeleteTopicsRequest from arguments
 func (*ResourceHelper) DeleteKafkaTopicRequest(topic string) *sarama.DeleteTopicsRequest {
 	return &sarama.DeleteTopicsRequest{
+		// version 1 adds the throttle time and is understood from 0.11,
+		// clusters with deletion disabled are detected beforehand
+		Version: 1,
 		Topics:  []string{topic},
 		Timeout: time.Second * 20,
 	}
 }
 
-// CreateKafkaPartitionRequest prepares sarama.CreatePartitionsRequest from arguments
-func (*ResourceHelper) CreateKafkaPartitionRequest(topic string, partitions int32) *sarama.CreatePartitionsRequest {
+// CreateKafkaPartitionRequest prepares sarama.CreatePartitionsRequest from arguments,
+// assignment holds the replicas of the new partitions only, in partition order
+func (*ResourceHelper) CreateKafkaPartitionRequest(topic string, partitions int32, assignment [][]int32) *sarama.CreatePartitionsRequest {
 	var topicPartition sarama.TopicPartition
 	topicPartition.Count = partitions
+	topicPartition.Assignment = assignment
 
 	var request sarama.CreatePartitionsRequest
 	request.Timeout = time.Second * 15
@@ -129,19 +158,105 @@ func (*ResourceHelper) GetKafkaMetadataRequest(topic string) *sarama.MetadataReq
 	}
 }
 
-func (*ResourceHelper) GetKafkaConfigsRequest(topic string, configNames []string) *sarama.DescribeConfigsRequest {
+// GetKafkaOffsetsRequest prepares sarama.OffsetRequest for the given partitions
+// of a topic, time being either sarama.OffsetOldest or sarama.OffsetNewest
+func (*ResourceHelper) GetKafkaOffsetsRequest(topic string, partitions []int32, time int64) *sarama.OffsetRequest {
+	request := &sarama.OffsetRequest{Version: 1}
+	for _, partition := range partitions {
+		request.AddBlock(topic, partition, time, 1)
+	}
+
+	return request
+}
+
+// GetKafkaTopicsOffsetsRequest prepares a single sarama.OffsetRequest for the
+// given partitions of several topics
+func (*ResourceHelper) GetKafkaTopicsOffsetsRequest(partitions map[string][]int32, time int64) *sarama.OffsetRequest {
+	request := &sarama.OffsetRequest{Version: 1}
+	for topic, ids := range partitions {
+		for _, partition := range ids {
+			request.AddBlock(topic, partition, time, 1)
+		}
+	}
+
+	return request
+}
+
+// GetKafkaGroupTopicsOffsetsRequest prepares a single
+// sarama.OffsetFetchRequest for the offsets committed by a consumer group on
+// the given partitions of several topics
+func (*ResourceHelper) GetKafkaGroupTopicsOffsetsRequest(group string, partitions map[string][]int32) *sarama.OffsetFetchRequest {
+	request := &sarama.OffsetFetchRequest{
+		Version:       1,
+		ConsumerGroup: group,
+	}
+	for topic, ids := range partitions {
+		for _, partition := range ids {
+			request.AddPartition(topic, partition)
+		}
+	}
+
+	return request
+}
+
+// GetKafkaGroupOffsetsRequest prepares sarama.OffsetFetchRequest for the
+// offsets committed by a consumer group on the given partitions of a topic
+func (*ResourceHelper) GetKafkaGroupOffsetsRequest(group string, topic string, partitions []int32) *sarama.OffsetFetchRequest {
+	request := &sarama.OffsetFetchRequest{
+		Version:       1,
+		ConsumerGroup: group,
+	}
+	for _, partition := range partitions {
+		request.AddPartition(topic, partition)
+	}
+
+	return request
+}
+
+// GetKafkaTopicMetadataRequest prepares a sarama.MetadataRequest which never auto creates the topic
+func (*ResourceHelper) GetKafkaTopicMetadataRequest(topic string) *sarama.MetadataRequest {
+	return &sarama.MetadataRequest{
+		// version 5 adds the offline replicas of each partition
+		Version:                5,
+		Topics:                 []string{topic},
+		AllowAutoTopicCreation: false,
+	}
+}
+
+// GetKafkaAllTopicsMetadataRequest prepares sarama.MetadataRequest for every topic of the cluster
+func (*ResourceHelper) GetKafkaAllTopicsMetadataRequest() *sarama.MetadataRequest {
+	return &sarama.MetadataRequest{
+		Version: 5,
+	}
+}
+
+// GetKafkaResourceConfigsRequest prepares sarama.DescribeConfigsRequest for
+// any config resource, version 1 reports the source of each entry
+func (*ResourceHelper) GetKafkaResourceConfigsRequest(resourceType sarama.ConfigResourceType, name string, configNames []string) *sarama.DescribeConfigsRequest {
 	return &sarama.DescribeConfigsRequest{
+		Version: 1,
 		Resources: []*sarama.ConfigResource{
 			{
-				Type:        sarama.TopicResource,
-				Name:        topic,
+				Type:        resourceType,
+				Name:        name,
 				ConfigNames: configNames,
 			},
 		},
 	}
 }
 
-func (*ResourceHelper) AlterTopicConfigsRequest(topic string, configs map[string]interface{}) *sarama.AlterConfigsRequest {
+func (h *ResourceHelper) GetKafkaConfigsRequest(topic string, configNames []string) *sarama.DescribeConfigsRequest {
+	return h.GetKafkaResourceConfigsRequest(sarama.TopicResource, topic, configNames)
+}
+
+// GetKafkaBrokerConfigsRequest prepares sarama.DescribeConfigsRequest for a single broker
+func (h *ResourceHelper) GetKafkaBrokerConfigsRequest(brokerID int32, configNames []string) *sarama.DescribeConfigsRequest {
+	return h.GetKafkaResourceConfigsRequest(sarama.BrokerResource, strconv.Itoa(int(brokerID)), configNames)
+}
+
+// AlterResourceConfigsRequest prepares sarama.AlterConfigsRequest for any
+// config resource, configs replace every dynamic entry of the resource
+func (*ResourceHelper) AlterResourceConfigsRequest(resourceType sarama.ConfigResourceType, name string, configs map[string]interface{}) *sarama.AlterConfigsRequest {
 	configEntries := make(map[string]*string, len(configs))
 	for config, entry := range configs {
 		entryValue := entry.(string)
@@ -150,10 +265,125 @@ func (*ResourceHelper) AlterTopicConfigsRequest(topic string, configs map[string
 	return &sarama.AlterConfigsRequest{
 		Resources: []*sarama.AlterConfigsResource{
 			{
-				Type:          sarama.TopicResource,
-				Name:          topic,
+				Type:          resourceType,
+				Name:          name,
 				ConfigEntries: configEntries,
 			},
 		},
 	}
 }
+
+// IncrementalAlterResourceConfigsRequest prepares
+// sarama.IncrementalAlterConfigsRequest setting some entries of a config
+// resource and leaving the others alone, nil values delete the entry
+func (*ResourceHelper) IncrementalAlterResourceConfigsRequest(resourceType sarama.ConfigResourceType, name string, configs map[string]*string) *sarama.IncrementalAlterConfigsRequest {
+	configEntries := make(map[string]sarama.IncrementalAlterConfigsEntry, len(configs))
+	for config, entry := range configs {
+		operation := sarama.IncrementalAlterConfigsOperationSet
+		if entry == nil {
+			operation = sarama.IncrementalAlterConfigsOperationDelete
+		}
+		configEntries[config] = sarama.IncrementalAlterConfigsEntry{
+			Operation: operation,
+			Value:     entry,
+		}
+	}
+	return &sarama.IncrementalAlterConfigsRequest{
+		Resources: []*sarama.IncrementalAlterConfigsResource{
+			{
+				Type:          resourceType,
+				Name:          name,
+				ConfigEntries: configEntries,
+			},
+		},
+	}
+}
+
+func (h *ResourceHelper) AlterTopicConfigsRequest(topic string, configs map[string]interface{}) *sarama.AlterConfigsRequest {
+	return h.AlterResourceConfigsRequest(sarama.TopicResource, topic, configs)
+}
+
+// CreateKafkaTopicsRequest prepares a single sarama.CreateTopicsRequest for several topics
+func (h *ResourceHelper) CreateKafkaTopicsRequest(topics []Topic) *sarama.CreateTopicsRequest {
+	topicDetails := make(map[string]*sarama.TopicDetail, len(topics))
+	for _, topic := range topics {
+		request := h.CreateKafkaTopicRequest(
+			topic.Name,
+			topic.Partitions,
+			topic.ReplicationFactor,
+			topic.ConfigEntries,
+			topic.ReplicaAssignment,
+		)
+		topicDetails[topic.Name] = request.TopicDetails[topic.Name]
+	}
+
+	return &sarama.CreateTopicsRequest{
+		Version:      1,
+		Timeout:      time.Second * 60,
+		TopicDetails: topicDetails,
+	}
+}
+
+// DeleteKafkaTopicsRequest prepares a single sarama.DeleteTopicsRequest for several topics
+func (*ResourceHelper) DeleteKafkaTopicsRequest(topics []string) *sarama.DeleteTopicsRequest {
+	return &sarama.DeleteTopicsRequest{
+		Version: 1,
+		Topics:  topics,
+		Timeout: time.Second * 60,
+	}
+}
+
+// CreateKafkaPartitionsRequest prepares a single sarama.CreatePartitionsRequest
+// raising the partition count of several topics, the new partitions being
+// placed on the replicas of assignments
+func (*ResourceHelper) CreateKafkaPartitionsRequest(partitions map[string]int32, assignments map[string][][]int32) *sarama.CreatePartitionsRequest {
+	topicPartitions := make(map[string]*sarama.TopicPartition, len(partitions))
+	for topic, count := range partitions {
+		topicPartitions[topic] = &sarama.TopicPartition{Count: count, Assignment: assignments[topic]}
+	}
+
+	return &sarama.CreatePartitionsRequest{
+		Timeout:         time.Second * 60,
+		TopicPartitions: topicPartitions,
+	}
+}
+
+// GetKafkaTopicsMetadataRequest prepares a single sarama.MetadataRequest for several topics
+func (*ResourceHelper) GetKafkaTopicsMetadataRequest(topics []string) *sarama.MetadataRequest {
+	return &sarama.MetadataRequest{
+		Version:                5,
+		Topics:                 topics,
+		AllowAutoTopicCreation: false,
+	}
+}
+
+// GetKafkaTopicsConfigsRequest prepares a single sarama.DescribeConfigsRequest for several topics
+func (*ResourceHelper) GetKafkaTopicsConfigsRequest(topics []string, configNames []string) *sarama.DescribeConfigsRequest {
+	resources := make([]*sarama.ConfigResource, 0, len(topics))
+	for _, topic := range topics {
+		resources = append(resources, &sarama.ConfigResource{
+			Type:        sarama.TopicResource,
+			Name:        topic,
+			ConfigNames: configNames,
+		})
+	}
+
+	return &sarama.DescribeConfigsRequest{
+		Resources: resources,
+	}
+}
+
+// IncrementalAlterTopicsConfigsRequest prepares a single
+// sarama.IncrementalAlterConfigsRequest for several topics, entries missing
+// from configs are left alone and nil values delete the entry
+func (h *ResourceHelper) IncrementalAlterTopicsConfigsRequest(configs map[string]map[string]*string) *sarama.IncrementalAlterConfigsRequest {
+	resources := make([]*sarama.IncrementalAlterConfigsResource, 0, len(configs))
+	for topic, entries := range configs {
+		request := h.IncrementalAlterResourceConfigsRequest(sarama.TopicResource, topic, entries)
+		resources = append(resources, request.Resources...)
+	}
+
+	return &sarama.IncrementalAlterConfigsRequest{
+		Resources: resources,
+	}
+}
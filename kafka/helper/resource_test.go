@@ -3,6 +3,7 @@ package helper
 import (
 	"testing"
 
+	"github.com/IBM/sarama"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,13 +24,30 @@ func TestCreateKafkaTopicRequest(t *testing.T) {
 	aReplicas := 2
 	configEntries := make(map[string]*string)
 
-	response := helper.CreateKafkaTopicRequest(aTopic, aPartition, aReplicas, configEntries)
+	response := helper.CreateKafkaTopicRequest(aTopic, aPartition, aReplicas, configEntries, nil)
 	assert.NotEmpty(t, response)
 	assert.NotNil(t, response)
 
 	assert.NotNil(t, response.TopicDetails[aTopic])
 	assert.Equal(t, aPartition, int(response.TopicDetails[aTopic].NumPartitions))
 	assert.Equal(t, aReplicas, int(response.TopicDetails[aTopic].ReplicationFactor))
+
+	assignment := map[int32][]int32{0: {1, 2}}
+	response = helper.CreateKafkaTopicRequest(aTopic, aPartition, aReplicas, configEntries, assignment)
+	assert.Equal(t, int32(-1), response.TopicDetails[aTopic].NumPartitions)
+	assert.Equal(t, int16(-1), response.TopicDetails[aTopic].ReplicationFactor)
+	assert.Equal(t, assignment, response.TopicDetails[aTopic].ReplicaAssignment)
+}
+
+func TestReplicaAssignment(t *testing.T) {
+	blocks := []interface{}{
+		map[string]interface{}{"partition": 0, "replicas": []interface{}{1, 2}},
+		map[string]interface{}{"partition": 1, "replicas": []interface{}{2, 3}},
+	}
+
+	assignment := ReplicaAssignment(blocks)
+	assert.Equal(t, map[int32][]int32{0: {1, 2}, 1: {2, 3}}, assignment)
+	assert.Empty(t, ReplicaAssignment(nil))
 }
 
 func TestDeleteKafkaTopicRequest(t *testing.T) {
@@ -40,16 +58,20 @@ func TestDeleteKafkaTopicRequest(t *testing.T) {
 	assert.NotNil(t, response)
 	assert.NotNil(t, response.Topics)
 	assert.Equal(t, topic, response.Topics[0])
+	assert.Equal(t, int16(1), response.Version)
 }
 
 func TestCreateKafkaPartitionRequest(t *testing.T) {
 	topic := "mytopic"
 	partition := int32(3)
 
-	res := helper.CreateKafkaPartitionRequest(topic, partition)
+	assignment := [][]int32{{1, 2}}
+
+	res := helper.CreateKafkaPartitionRequest(topic, partition, assignment)
 	assert.NotEmpty(t, res)
 	assert.NotNil(t, res)
 	assert.Equal(t, res.TopicPartitions[topic].Count, partition)
+	assert.Equal(t, res.TopicPartitions[topic].Assignment, assignment)
 }
 
 func TestGetKafkaMetadataRequest(t *testing.T) {
@@ -59,3 +81,137 @@ func TestGetKafkaMetadataRequest(t *testing.T) {
 	assert.NotNil(t, res)
 	assert.Equal(t, res.Topics[0], topic)
 }
+
+func TestGetKafkaTopicMetadataRequest(t *testing.T) {
+	topic := "mytopic"
+	res := helper.GetKafkaTopicMetadataRequest(topic)
+	assert.NotNil(t, res)
+	assert.Equal(t, res.Topics[0], topic)
+	assert.False(t, res.AllowAutoTopicCreation)
+}
+
+func TestGetKafkaBrokerConfigsRequest(t *testing.T) {
+	res := helper.GetKafkaBrokerConfigsRequest(1, []string{"delete.topic.enable"})
+	assert.NotNil(t, res)
+	assert.Equal(t, sarama.BrokerResource, res.Resources[0].Type)
+	assert.Equal(t, "1", res.Resources[0].Name)
+	assert.Equal(t, "delete.topic.enable", res.Resources[0].ConfigNames[0])
+}
+
+func TestGetKafkaResourceConfigsRequest(t *testing.T) {
+	res := helper.GetKafkaResourceConfigsRequest(sarama.BrokerResource, "", nil)
+	assert.Equal(t, int16(1), res.Version)
+	assert.Equal(t, sarama.BrokerResource, res.Resources[0].Type)
+	assert.Equal(t, "", res.Resources[0].Name)
+	assert.Nil(t, res.Resources[0].ConfigNames)
+}
+
+func TestAlterResourceConfigsRequest(t *testing.T) {
+	res := helper.AlterResourceConfigsRequest(sarama.BrokerResource, "2", map[string]interface{}{"log.cleaner.threads": "2"})
+	assert.Equal(t, sarama.BrokerResource, res.Resources[0].Type)
+	assert.Equal(t, "2", res.Resources[0].Name)
+	assert.Equal(t, "2", *res.Resources[0].ConfigEntries["log.cleaner.threads"])
+
+	res = helper.AlterTopicConfigsRequest("mytopic", map[string]interface{}{})
+	assert.Equal(t, sarama.TopicResource, res.Resources[0].Type)
+	assert.Empty(t, res.Resources[0].ConfigEntries)
+}
+
+func TestIncrementalAlterResourceConfigsRequest(t *testing.T) {
+	level := "DEBUG"
+	res := helper.IncrementalAlterResourceConfigsRequest(sarama.BrokerLoggerResource, "1", map[string]*string{
+		"kafka.controller": &level,
+		"kafka.log":        nil,
+	})
+	assert.Equal(t, sarama.BrokerLoggerResource, res.Resources[0].Type)
+	assert.Equal(t, "1", res.Resources[0].Name)
+
+	entry := res.Resources[0].ConfigEntries["kafka.controller"]
+	assert.Equal(t, sarama.IncrementalAlterConfigsOperationSet, entry.Operation)
+	assert.Equal(t, "DEBUG", *entry.Value)
+
+	entry = res.Resources[0].ConfigEntries["kafka.log"]
+	assert.Equal(t, sarama.IncrementalAlterConfigsOperationDelete, entry.Operation)
+	assert.Nil(t, entry.Value)
+}
+
+func TestGetKafkaOffsetsRequest(t *testing.T) {
+	res := helper.GetKafkaOffsetsRequest("mytopic", []int32{0, 1}, sarama.OffsetOldest)
+	assert.NotNil(t, res)
+	assert.Equal(t, int16(1), res.Version)
+}
+
+func TestGetKafkaTopicsOffsetsRequest(t *testing.T) {
+	res := helper.GetKafkaTopicsOffsetsRequest(map[string][]int32{"first": {0, 1}, "second": {0}}, sarama.OffsetNewest)
+	assert.NotNil(t, res)
+	assert.Equal(t, int16(1), res.Version)
+}
+
+func TestGetKafkaGroupTopicsOffsetsRequest(t *testing.T) {
+	res := helper.GetKafkaGroupTopicsOffsetsRequest("mygroup", map[string][]int32{"first": {0, 1}, "second": {0}})
+	assert.NotNil(t, res)
+	assert.Equal(t, "mygroup", res.ConsumerGroup)
+	assert.Equal(t, int16(1), res.Version)
+}
+
+func TestGetKafkaGroupOffsetsRequest(t *testing.T) {
+	res := helper.GetKafkaGroupOffsetsRequest("mygroup", "mytopic", []int32{0, 1})
+	assert.NotNil(t, res)
+	assert.Equal(t, "mygroup", res.ConsumerGroup)
+	assert.Equal(t, int16(1), res.Version)
+}
+
+func TestCreateKafkaTopicsRequest(t *testing.T) {
+	topics := []Topic{
+		{Name: "first", Partitions: 1, ReplicationFactor: 1},
+		{Name: "second", Partitions: 3, ReplicationFactor: 2},
+	}
+
+	res := helper.CreateKafkaTopicsRequest(topics)
+	assert.Len(t, res.TopicDetails, 2)
+	assert.Equal(t, int32(3), res.TopicDetails["second"].NumPartitions)
+	assert.Equal(t, int16(2), res.TopicDetails["second"].ReplicationFactor)
+}
+
+func TestDeleteKafkaTopicsRequest(t *testing.T) {
+	res := helper.DeleteKafkaTopicsRequest([]string{"first", "second"})
+	assert.Equal(t, []string{"first", "second"}, res.Topics)
+	assert.Equal(t, int16(1), res.Version)
+}
+
+func TestCreateKafkaPartitionsRequest(t *testing.T) {
+	res := helper.CreateKafkaPartitionsRequest(
+		map[string]int32{"first": 3, "second": 6},
+		map[string][][]int32{"second": {{1, 2}, {2, 3}}},
+	)
+	assert.Len(t, res.TopicPartitions, 2)
+	assert.Equal(t, int32(6), res.TopicPartitions["second"].Count)
+	assert.Equal(t, [][]int32{{1, 2}, {2, 3}}, res.TopicPartitions["second"].Assignment)
+	assert.Nil(t, res.TopicPartitions["first"].Assignment)
+}
+
+func TestGetKafkaTopicsConfigsRequest(t *testing.T) {
+	res := helper.GetKafkaTopicsConfigsRequest([]string{"first", "second"}, nil)
+	assert.Len(t, res.Resources, 2)
+	assert.Equal(t, "second", res.Resources[1].Name)
+	assert.Equal(t, sarama.TopicResource, res.Resources[1].Type)
+}
+
+func TestIncrementalAlterTopicsConfigsRequest(t *testing.T) {
+	retention := "1000"
+	res := helper.IncrementalAlterTopicsConfigsRequest(map[string]map[string]*string{
+		"first":  {"retention.ms": &retention},
+		"second": {"cleanup.policy": nil},
+	})
+	assert.Len(t, res.Resources, 2)
+
+	for _, resource := range res.Resources {
+		assert.Equal(t, sarama.TopicResource, resource.Type)
+		switch resource.Name {
+		case "first":
+			assert.Equal(t, sarama.IncrementalAlterConfigsOperationSet, resource.ConfigEntries["retention.ms"].Operation)
+		case "second":
+			assert.Equal(t, sarama.IncrementalAlterConfigsOperationDelete, resource.ConfigEntries["cleanup.policy"].Operation)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"github.com/IBM/sarama"
+)
+
+// DescribeKafkaUserScramCredentialsRequest prepares
+// sarama.DescribeUserScramCredentialsRequest for the given users
+func (*ResourceHelper) DescribeKafkaUserScramCredentialsRequest(users []string) *sarama.DescribeUserScramCredentialsRequest {
+	describeUsers := make([]sarama.DescribeUserScramCredentialsRequestUser, 0, len(users))
+	for _, user := range users {
+		describeUsers = append(describeUsers, sarama.DescribeUserScramCredentialsRequestUser{Name: user})
+	}
+
+	return &sarama.DescribeUserScramCredentialsRequest{
+		DescribeUsers: describeUsers,
+	}
+}
+
+// UpsertKafkaUserScramCredentialRequest prepares
+// sarama.AlterUserScramCredentialsRequest creating or replacing a credential,
+// sarama only sends the password salted
+func (*ResourceHelper) UpsertKafkaUserScramCredentialRequest(user string, mechanism sarama.ScramMechanismType, iterations int32, salt []byte, password []byte) *sarama.AlterUserScramCredentialsRequest {
+	return &sarama.AlterUserScramCredentialsRequest{
+		Upsertions: []sarama.AlterUserScramCredentialsUpsert{
+			{
+				Name:       user,
+				Mechanism:  mechanism,
+				Iterations: iterations,
+				Salt:       salt,
+				Password:   password,
+			},
+		},
+	}
+}
+
+// DeleteKafkaUserScramCredentialRequest prepares
+// sarama.AlterUserScramCredentialsRequest removing a credential
+func (*ResourceHelper) DeleteKafkaUserScramCredentialRequest(user string, mechanism sarama.ScramMechanismType) *sarama.AlterUserScramCredentialsRequest {
+	return &sarama.AlterUserScramCredentialsRequest{
+		Deletions: []sarama.AlterUserScramCredentialsDelete{
+			{
+				Name:      user,
+				Mechanism: mechanism,
+			},
+		},
+	}
+}
@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeKafkaUserScramCredentialsRequest(t *testing.T) {
+	res := helper.DescribeKafkaUserScramCredentialsRequest([]string{"alice"})
+	assert.Equal(t, []sarama.DescribeUserScramCredentialsRequestUser{{Name: "alice"}}, res.DescribeUsers)
+}
+
+func TestUpsertKafkaUserScramCredentialRequest(t *testing.T) {
+	res := helper.UpsertKafkaUserScramCredentialRequest("alice", sarama.SCRAM_MECHANISM_SHA_512, 8192, []byte("salt"), []byte("secret"))
+	assert.Empty(t, res.Deletions)
+	assert.Len(t, res.Upsertions, 1)
+	assert.Equal(t, "alice", res.Upsertions[0].Name)
+	assert.Equal(t, sarama.SCRAM_MECHANISM_SHA_512, res.Upsertions[0].Mechanism)
+	assert.Equal(t, int32(8192), res.Upsertions[0].Iterations)
+	assert.Equal(t, []byte("salt"), res.Upsertions[0].Salt)
+	assert.Equal(t, []byte("secret"), res.Upsertions[0].Password)
+}
+
+func TestDeleteKafkaUserScramCredentialRequest(t *testing.T) {
+	res := helper.DeleteKafkaUserScramCredentialRequest("alice", sarama.SCRAM_MECHANISM_SHA_256)
+	assert.Empty(t, res.Upsertions)
+	assert.Equal(t, []sarama.AlterUserScramCredentialsDelete{
+		{Name: "alice", Mechanism: sarama.SCRAM_MECHANISM_SHA_256},
+	}, res.Deletions)
+}
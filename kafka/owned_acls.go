@@ -0,0 +1,156 @@
+package kafka
+
+import (
+	"log"
+	"sort"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// ownedAclsSchema lists the IDs of the ACLs a resource generated and owns
+func ownedAclsSchema(description string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeSet,
+		Computed:    true,
+		Description: description,
+		Elem:        &schema.Schema{Type: schema.TypeString},
+	}
+}
+
+func aclIDs(acls []helper.Acl) []string {
+	ids := make([]string, 0, len(acls))
+	for _, acl := range acls {
+		ids = append(ids, aclID(acl))
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func parseAclIDs(ids []interface{}) ([]helper.Acl, error) {
+	acls := make([]helper.Acl, 0, len(ids))
+	for _, id := range ids {
+		acl, err := parseAclID(id.(string))
+		if err != nil {
+			return nil, err
+		}
+		acls = append(acls, acl)
+	}
+	sortAcls(acls)
+
+	return acls, nil
+}
+
+// customizeDiffOwnedAcls plans the acls attribute of a resource generating
+// ACLs. ACLs removed out of band were dropped from acls on refresh and are
+// planned again.
+func customizeDiffOwnedAcls(d *schema.ResourceDiff, acls []helper.Acl) error {
+	desired := aclIDs(acls)
+
+	current := d.Get("acls").(*schema.Set)
+	if d.Id() != "" && current.Len() == len(desired) {
+		unchanged := true
+		for _, id := range desired {
+			if !current.Contains(id) {
+				unchanged = false
+			}
+		}
+
+		if unchanged {
+			return nil
+		}
+	}
+
+	return d.SetNew("acls", desired)
+}
+
+// applyOwnedAcls creates the desired ACLs and releases the owned ACLs which
+// are no longer desired, acls is updated with what was applied. ACLs which
+// release keeps still exist and stay in acls.
+func applyOwnedAcls(d *schema.ResourceData, broker *adminBroker, desired []helper.Acl, release func([]helper.Acl) ([]helper.Acl, error)) error {
+	oldVal, _ := d.GetChange("acls")
+	owned, err := parseAclIDs(oldVal.(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	// creating an existing ACL is a no-op, missing ones are restored
+	err = createAcls(broker, desired)
+	if err != nil {
+		d.Set("acls", aclIDs(owned))
+		return err
+	}
+
+	_, released := diffAcls(owned, desired)
+	kept, err := release(released)
+	if err != nil {
+		d.Set("acls", aclIDs(append(desired, released...)))
+		return err
+	}
+
+	d.Set("acls", aclIDs(append(desired, kept...)))
+	return nil
+}
+
+// readOwnedAcls keeps the owned ACLs which still exist on the cluster
+func readOwnedAcls(broker *adminBroker, ids []interface{}) ([]string, error) {
+	owned, err := parseAclIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := existingAcls(broker, owned)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []helper.Acl
+	for _, acl := range owned {
+		if existing[acl] {
+			found = append(found, acl)
+			continue
+		}
+
+		log.Printf("[WARN] Kafka: ACL %s was removed out of band", aclID(acl))
+	}
+
+	return aclIDs(found), nil
+}
+
+// existingAcls reports which of the ACLs exist on the cluster
+func existingAcls(broker *adminBroker, acls []helper.Acl) (map[helper.Acl]bool, error) {
+	// one request per resource rather than per ACL
+	filters := make(map[sarama.Resource]*sarama.AclFilter)
+	for _, acl := range acls {
+		resource := sarama.Resource{ResourceType: acl.ResourceType, ResourceName: acl.ResourceName, ResourcePatternType: acl.PatternType}
+		if _, ok := filters[resource]; ok {
+			continue
+		}
+
+		name := acl.ResourceName
+		filters[resource] = &sarama.AclFilter{
+			Version:                   1,
+			ResourceType:              acl.ResourceType,
+			ResourceName:              &name,
+			ResourcePatternTypeFilter: acl.PatternType,
+			Operation:                 sarama.AclOperationAny,
+			PermissionType:            sarama.AclPermissionAny,
+		}
+	}
+
+	existing := make(map[helper.Acl]bool)
+	for _, filter := range filters {
+		described, err := describeAcls(broker, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, acl := range described {
+			existing[acl] = true
+		}
+	}
+
+	return existing, nil
+}
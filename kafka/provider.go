@@ -4,8 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
 
-	"github.com/Shopify/sarama"
+	"github.com/IBM/sarama"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 )
@@ -27,13 +28,48 @@ func Provider() terraform.ResourceProvider {
 						errors = append(errors, fmt.Errorf("Broker list must not be an empty string"))
 					}
 
+					return
+				},
+			},
+			"deletion_protection": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Default deletion_protection of topics which do not set it",
+			},
+			"topic_name_pattern": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "Regular expression every topic name has to match",
+				ValidateFunc: validateTopicNamePattern,
+			},
+			"max_concurrent_admin_requests": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     defaultMaxConcurrentAdminRequests,
+				Description: "Maximum number of admin requests sent to the cluster at the same time",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errors []error) {
+					if v.(int) < 1 {
+						errors = append(errors, fmt.Errorf("%s must be at least 1, got %d", k, v.(int)))
+					}
+
 					return
 				},
 			},
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"kafka_topic": resourceKafkaTopic(),
+			"kafka_topic":                 resourceKafkaTopic(),
+			"kafka_topics":                resourceKafkaTopics(),
+			"kafka_acl":                   resourceKafkaAcl(),
+			"kafka_acl_set":               resourceKafkaAclSet(),
+			"kafka_role":                  resourceKafkaRole(),
+			"kafka_role_binding":          resourceKafkaRoleBinding(),
+			"kafka_broker_config":         resourceKafkaBrokerConfig(),
+			"kafka_broker_logger":         resourceKafkaBrokerLogger(),
+			"kafka_quota":                 resourceKafkaQuota(),
+			"kafka_user_scram_credential": resourceKafkaUserScramCredential(),
+			"kafka_service_account":       resourceKafkaServiceAccount(),
 		},
 
 		ConfigureFunc: provideConfigure,
@@ -41,28 +77,49 @@ func Provider() terraform.ResourceProvider {
 
 }
 
+// providerMeta is handed to every resource operation
+type providerMeta struct {
+	brokerList         string
+	broker             *adminBroker
+	deletionProtection bool
+	topicNamePattern   *regexp.Regexp
+	topics             *topicCache
+}
+
 func provideConfigure(d *schema.ResourceData) (interface{}, error) {
 	brokerList := d.Get("broker_list").(string)
 
+	topicNamePattern, err := compileTopicNamePattern(d.Get("topic_name_pattern").(string))
+	if err != nil {
+		return nil, err
+	}
+
 	broker, err := brokerConnection(brokerList)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return broker, nil
+	// every admin request of the run goes through the same scheduler
+	scheduler := newAdminScheduler(d.Get("max_concurrent_admin_requests").(int))
+
+	meta := &providerMeta{
+		brokerList:         brokerList,
+		broker:             scheduler.broker(broker),
+		deletionProtection: d.Get("deletion_protection").(bool),
+		topicNamePattern:   topicNamePattern,
+		topics:             newTopicCache(),
+	}
+
+	return meta, nil
 }
 
 func brokerConnection(brokerList string) (*sarama.Broker, error) {
 	// new broker instance
 	broker := sarama.NewBroker(brokerList)
 
-	// broker configuration
-	config := sarama.NewConfig()
-	config.Version = sarama.V0_10_2_1
-
 	// open broker with defined broker configuration
-	err := broker.Open(config)
+	err := broker.Open(brokerConfig())
 	if err != nil {
 		log.Println("Error establishing connection to broker ", err.Error())
 		return nil, err
@@ -80,3 +137,29 @@ func brokerConnection(brokerList string) (*sarama.Broker, error) {
 
 	return broker, nil
 }
+
+// brokerConfig returns the sarama configuration used for every broker connection
+func brokerConfig() *sarama.Config {
+	config := sarama.NewConfig()
+	// sarama refuses requests newer than this version, prefixed ACLs need
+	// at least 2.0, broker logger levels IncrementalAlterConfigs from 2.3,
+	// client quotas 2.6, SCRAM credentials 2.7 and topic IDs in Metadata v10
+	// 2.8, the Metadata version itself is negotiated with the cluster
+	config.Version = sarama.V2_8_0_0
+	// from 2.4 sarama identifies itself with a fire-and-forget
+	// ApiVersionsRequest on connect, which the admin requests do not need
+	config.ApiVersionsRequest = false
+
+	return config
+}
+
+// openBroker connects to a broker discovered through metadata
+func openBroker(broker *sarama.Broker) error {
+	err := broker.Open(brokerConfig())
+	if err != nil && err != sarama.ErrAlreadyConnected {
+		log.Printf("Error establishing connection to broker %s: %s", broker.Addr(), err.Error())
+		return err
+	}
+
+	return nil
+}
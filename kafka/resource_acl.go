@@ -0,0 +1,144 @@
+package kafka
+
+import (
+	"log"
+
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceKafkaAcl() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKafkaAclCreate,
+		Read:   resourceKafkaAclRead,
+		Delete: resourceKafkaAclDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceKafkaAclImport,
+		},
+		Schema: kafkaAclSchema(),
+	}
+}
+
+// kafkaAclSchema describes a single ACL, ACLs cannot be altered so every
+// change replaces the ACL
+func kafkaAclSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"resource_type": &schema.Schema{
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			Description:  "Type of the resource the ACL applies to",
+			ValidateFunc: validateAclValue(aclResourceTypes),
+		},
+		"resource_name": &schema.Schema{
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "Name of the resource, or name prefix with the Prefixed pattern type",
+		},
+		"resource_pattern_type": &schema.Schema{
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			Default:      "Literal",
+			Description:  "How resource_name matches resources",
+			ValidateFunc: validateAclValue(aclPatternTypes),
+		},
+		"principal": &schema.Schema{
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "Principal the ACL applies to, such as User:alice",
+		},
+		"host": &schema.Schema{
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     "*",
+			Description: "Host the principal connects from",
+		},
+		"operation": &schema.Schema{
+			Type:         schema.TypeString,
+			Required:     true,
+			ForceNew:     true,
+			Description:  "Operation allowed or denied",
+			ValidateFunc: validateAclValue(aclOperations),
+		},
+		"permission_type": &schema.Schema{
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			Default:      "Allow",
+			Description:  "Whether the operation is allowed or denied",
+			ValidateFunc: validateAclValue(aclPermissionTypes),
+		},
+	}
+}
+
+func resourceKafkaAclCreate(d *schema.ResourceData, m interface{}) error {
+	broker := m.(*providerMeta).broker
+
+	acl, err := expandAcl(d)
+	if err != nil {
+		return err
+	}
+
+	err = createAcls(broker, []helper.Acl{acl})
+	if err != nil {
+		return err
+	}
+
+	d.SetId(aclID(acl))
+	return resourceKafkaAclRead(d, m)
+}
+
+func resourceKafkaAclRead(d *schema.ResourceData, m interface{}) error {
+	broker := m.(*providerMeta).broker
+
+	acl, err := parseAclID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	exists, err := aclExists(broker, acl)
+	if err != nil {
+		return err
+	}
+
+	// removed out of band, the next plan creates it again
+	if !exists {
+		log.Printf("[WARN] Kafka: ACL %s no longer exists, removing it from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	for key, value := range flattenAcl(acl) {
+		d.Set(key, value)
+	}
+
+	return nil
+}
+
+func resourceKafkaAclDelete(d *schema.ResourceData, m interface{}) error {
+	broker := m.(*providerMeta).broker
+
+	acl, err := parseAclID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	return deleteAcls(broker, []helper.Acl{acl})
+}
+
+// resourceKafkaAclImport accepts the ID of the ACL, see aclID
+func resourceKafkaAclImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	acl, err := parseAclID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	// normalize the case of the imported values
+	d.SetId(aclID(acl))
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,446 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Prefixes of the kafka_acl_set IDs, one per scope
+const (
+	aclSetPrincipalScope = "principal"
+	aclSetResourceScope  = "resource"
+)
+
+func resourceKafkaAclSet() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKafkaAclSetCreate,
+		Read:   resourceKafkaAclSetRead,
+		Update: resourceKafkaAclSetUpdate,
+		Delete: resourceKafkaAclSetDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceKafkaAclSetImport,
+		},
+		CustomizeDiff: resourceKafkaAclSetCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"principal": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				Description:   "Principal whose ACLs are all owned by this set",
+				ConflictsWith: []string{"resource_type", "resource_name"},
+			},
+			"resource_type": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Description:  "Type of the resource whose ACLs are all owned by this set",
+				ValidateFunc: validateAclValue(aclResourceTypes),
+			},
+			"resource_name": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Name of the resource whose ACLs are all owned by this set",
+			},
+			"resource_pattern_type": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "Literal",
+				Description:  "Pattern type of the resource whose ACLs are all owned by this set",
+				ValidateFunc: validateAclValue(aclPatternTypes),
+			},
+			"acl": &schema.Schema{
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "ACLs of the principal or of the resource, any other ACL of the scope is removed",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"resource_type": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "Type of the resource, only in sets scoped to a principal",
+							ValidateFunc: validateAclValue(aclResourceTypes),
+						},
+						"resource_name": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Name of the resource, only in sets scoped to a principal",
+						},
+						"resource_pattern_type": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "Literal",
+							Description:  "Pattern type of the resource, only in sets scoped to a principal",
+							ValidateFunc: validateAclValue(aclPatternTypes),
+						},
+						"principal": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Principal, only in sets scoped to a resource",
+						},
+						"host": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "*",
+						},
+						"operation": &schema.Schema{
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateAclValue(aclOperations),
+						},
+						"permission_type": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "Allow",
+							ValidateFunc: validateAclValue(aclPermissionTypes),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// aclSetScope is the principal or the resource whose ACLs a set owns
+type aclSetScope struct {
+	principal    string
+	resourceType string
+	resourceName string
+	patternType  string
+}
+
+func (s aclSetScope) byPrincipal() bool {
+	return s.principal != ""
+}
+
+func expandAclSetScope(d aclGetter) (aclSetScope, error) {
+	scope := aclSetScope{
+		principal:    d.Get("principal").(string),
+		resourceType: d.Get("resource_type").(string),
+		resourceName: d.Get("resource_name").(string),
+		patternType:  d.Get("resource_pattern_type").(string),
+	}
+
+	if scope.principal == "" && (scope.resourceType == "" || scope.resourceName == "") {
+		return scope, fmt.Errorf("Set either principal, or resource_type and resource_name")
+	}
+
+	return scope, nil
+}
+
+// filter matches every ACL of the scope
+func (s aclSetScope) filter() (*sarama.AclFilter, error) {
+	filter := &sarama.AclFilter{
+		Version:                   1,
+		ResourceType:              sarama.AclResourceAny,
+		ResourcePatternTypeFilter: sarama.AclPatternAny,
+		Operation:                 sarama.AclOperationAny,
+		PermissionType:            sarama.AclPermissionAny,
+	}
+
+	if s.byPrincipal() {
+		principal := s.principal
+		filter.Principal = &principal
+		return filter, nil
+	}
+
+	if err := filter.ResourceType.UnmarshalText([]byte(s.resourceType)); err != nil {
+		return nil, err
+	}
+
+	if err := filter.ResourcePatternTypeFilter.UnmarshalText([]byte(s.patternType)); err != nil {
+		return nil, err
+	}
+
+	resourceName := s.resourceName
+	filter.ResourceName = &resourceName
+
+	return filter, nil
+}
+
+// id returns the ID of the set, principal|<principal> or
+// resource|<type>|<pattern type>|<name>
+func (s aclSetScope) id() string {
+	if s.byPrincipal() {
+		return strings.Join([]string{aclSetPrincipalScope, s.principal}, aclIDSeparator)
+	}
+
+	return strings.Join([]string{aclSetResourceScope, s.resourceType, s.patternType, s.resourceName}, aclIDSeparator)
+}
+
+func parseAclSetID(id string) (aclSetScope, error) {
+	parts := strings.SplitN(id, aclIDSeparator, 4)
+
+	switch {
+	case len(parts) == 2 && parts[0] == aclSetPrincipalScope:
+		return aclSetScope{principal: parts[1], patternType: "Literal"}, nil
+	case len(parts) == 4 && parts[0] == aclSetResourceScope:
+		// normalize the case of imported values
+		var resourceType sarama.AclResourceType
+		if err := resourceType.UnmarshalText([]byte(parts[1])); err != nil {
+			return aclSetScope{}, err
+		}
+
+		var patternType sarama.AclResourcePatternType
+		if err := patternType.UnmarshalText([]byte(parts[2])); err != nil {
+			return aclSetScope{}, err
+		}
+
+		return aclSetScope{resourceType: resourceType.String(), patternType: patternType.String(), resourceName: parts[3]}, nil
+	default:
+		return aclSetScope{}, fmt.Errorf(
+			"ACL set ID %q must be principal|<principal> or resource|<resource_type>|<resource_pattern_type>|<resource_name>", id,
+		)
+	}
+}
+
+// validateAclSetBlock checks a block only sets the attributes which the scope
+// does not already fix
+func validateAclSetBlock(scope aclSetScope, block map[string]interface{}) error {
+	if scope.byPrincipal() {
+		if block["principal"].(string) != "" {
+			return fmt.Errorf("acl blocks of a set scoped to principal %s cannot set principal", scope.principal)
+		}
+
+		if block["resource_type"].(string) == "" || block["resource_name"].(string) == "" {
+			return fmt.Errorf("acl blocks of a set scoped to a principal need resource_type and resource_name")
+		}
+
+		return nil
+	}
+
+	if block["resource_type"].(string) != "" || block["resource_name"].(string) != "" || block["resource_pattern_type"].(string) != "Literal" {
+		return fmt.Errorf("acl blocks of a set scoped to a resource cannot set resource_type, resource_name or resource_pattern_type")
+	}
+
+	if block["principal"].(string) == "" {
+		return fmt.Errorf("acl blocks of a set scoped to a resource need a principal")
+	}
+
+	return nil
+}
+
+// expandAclSetBlock completes a block with the fields fixed by the scope
+func expandAclSetBlock(scope aclSetScope, block map[string]interface{}) (helper.Acl, error) {
+	acl := make(aclBlock, len(block))
+	for key, value := range block {
+		acl[key] = value
+	}
+
+	if scope.byPrincipal() {
+		acl["principal"] = scope.principal
+	} else {
+		acl["resource_type"] = scope.resourceType
+		acl["resource_name"] = scope.resourceName
+		acl["resource_pattern_type"] = scope.patternType
+	}
+
+	return expandAcl(acl)
+}
+
+// flattenAclSetBlock drops the fields fixed by the scope, the reverse of expandAclSetBlock
+func flattenAclSetBlock(scope aclSetScope, acl helper.Acl) map[string]interface{} {
+	block := flattenAcl(acl)
+
+	if scope.byPrincipal() {
+		block["principal"] = ""
+	} else {
+		block["resource_type"] = ""
+		block["resource_name"] = ""
+		block["resource_pattern_type"] = "Literal"
+	}
+
+	return block
+}
+
+func expandAclSetBlocks(scope aclSetScope, set *schema.Set) ([]helper.Acl, error) {
+	acls := make([]helper.Acl, 0, set.Len())
+	for _, item := range set.List() {
+		block := item.(map[string]interface{})
+		if err := validateAclSetBlock(scope, block); err != nil {
+			return nil, err
+		}
+
+		acl, err := expandAclSetBlock(scope, block)
+		if err != nil {
+			return nil, err
+		}
+		acls = append(acls, acl)
+	}
+	sortAcls(acls)
+
+	return acls, nil
+}
+
+// diffAcls returns the desired ACLs missing from current, and the current
+// ACLs which are not desired
+func diffAcls(current, desired []helper.Acl) ([]helper.Acl, []helper.Acl) {
+	existing := make(map[helper.Acl]bool, len(current))
+	for _, acl := range current {
+		existing[acl] = true
+	}
+
+	wanted := make(map[helper.Acl]bool, len(desired))
+	var missing []helper.Acl
+	for _, acl := range desired {
+		wanted[acl] = true
+		if !existing[acl] {
+			missing = append(missing, acl)
+		}
+	}
+
+	var unmanaged []helper.Acl
+	for _, acl := range current {
+		if !wanted[acl] {
+			unmanaged = append(unmanaged, acl)
+		}
+	}
+
+	return missing, unmanaged
+}
+
+func resourceKafkaAclSetCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	// checked at apply time when the scope or the blocks are interpolated
+	for _, key := range []string{"principal", "resource_type", "resource_name", "acl"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+
+	scope, err := expandAclSetScope(d)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range d.Get("acl").(*schema.Set).List() {
+		if err := validateAclSetBlock(scope, item.(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func resourceKafkaAclSetCreate(d *schema.ResourceData, m interface{}) error {
+	scope, err := expandAclSetScope(d)
+	if err != nil {
+		return err
+	}
+
+	err = applyAclSet(m.(*providerMeta).broker, scope, d.Get("acl").(*schema.Set))
+	if err != nil {
+		return err
+	}
+
+	d.SetId(scope.id())
+	return resourceKafkaAclSetRead(d, m)
+}
+
+func resourceKafkaAclSetRead(d *schema.ResourceData, m interface{}) error {
+	broker := m.(*providerMeta).broker
+
+	scope, err := parseAclSetID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	filter, err := scope.filter()
+	if err != nil {
+		return err
+	}
+
+	acls, err := describeAcls(broker, filter)
+	if err != nil {
+		return err
+	}
+
+	// every ACL of the scope is read, unmanaged ones show up as drift
+	blocks := make([]interface{}, 0, len(acls))
+	for _, acl := range acls {
+		blocks = append(blocks, flattenAclSetBlock(scope, acl))
+	}
+
+	d.Set("principal", scope.principal)
+	d.Set("resource_type", scope.resourceType)
+	d.Set("resource_name", scope.resourceName)
+	d.Set("resource_pattern_type", scope.patternType)
+	d.Set("acl", blocks)
+
+	return nil
+}
+
+func resourceKafkaAclSetUpdate(d *schema.ResourceData, m interface{}) error {
+	scope, err := expandAclSetScope(d)
+	if err != nil {
+		return err
+	}
+
+	err = applyAclSet(m.(*providerMeta).broker, scope, d.Get("acl").(*schema.Set))
+	if err != nil {
+		return err
+	}
+
+	return resourceKafkaAclSetRead(d, m)
+}
+
+func resourceKafkaAclSetDelete(d *schema.ResourceData, m interface{}) error {
+	scope, err := parseAclSetID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	return applyAclSet(m.(*providerMeta).broker, scope, schema.NewSet(schema.HashString, nil))
+}
+
+// resourceKafkaAclSetImport accepts the ID of the set, see aclSetScope.id
+func resourceKafkaAclSetImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	scope, err := parseAclSetID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(scope.id())
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// applyAclSet makes the ACLs of the scope on the cluster match the blocks,
+// ACLs are compared with the cluster rather than the state so that bindings
+// added since the last refresh are removed as well
+func applyAclSet(broker *adminBroker, scope aclSetScope, blocks *schema.Set) error {
+	desired, err := expandAclSetBlocks(scope, blocks)
+	if err != nil {
+		return err
+	}
+
+	filter, err := scope.filter()
+	if err != nil {
+		return err
+	}
+
+	current, err := describeAcls(broker, filter)
+	if err != nil {
+		return err
+	}
+
+	missing, unmanaged := diffAcls(current, desired)
+
+	// Granting first, a failure midway never leaves the principals with
+	// less access than both the old and the new set
+	err = createAcls(broker, missing)
+	if err != nil {
+		return err
+	}
+
+	for _, acl := range unmanaged {
+		log.Printf("[DEBUG] Kafka: removing ACL %s from set %s", aclID(acl), scope.id())
+	}
+
+	return deleteAcls(broker, unmanaged)
+}
@@ -0,0 +1,148 @@
+package kafka
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+var principalScope = aclSetScope{principal: "User:alice", patternType: "Literal"}
+
+var topicScope = aclSetScope{resourceType: "Topic", resourceName: "orders", patternType: "Prefixed"}
+
+func TestAclSetID(t *testing.T) {
+	assert.Equal(t, "principal|User:alice", principalScope.id())
+	assert.Equal(t, "resource|Topic|Prefixed|orders", topicScope.id())
+
+	scope, err := parseAclSetID("principal|User:alice")
+	assert.NoError(t, err)
+	assert.Equal(t, principalScope, scope)
+
+	scope, err = parseAclSetID("resource|topic|prefixed|orders")
+	assert.NoError(t, err)
+	assert.Equal(t, topicScope, scope)
+
+	_, err = parseAclSetID("User:alice")
+	assert.Error(t, err)
+}
+
+func TestAclSetScopeFilter(t *testing.T) {
+	filter, err := principalScope.filter()
+	assert.NoError(t, err)
+	assert.Equal(t, "User:alice", *filter.Principal)
+	assert.Nil(t, filter.ResourceName)
+	assert.Equal(t, sarama.AclResourceAny, filter.ResourceType)
+	assert.Equal(t, sarama.AclPatternAny, filter.ResourcePatternTypeFilter)
+
+	filter, err = topicScope.filter()
+	assert.NoError(t, err)
+	assert.Nil(t, filter.Principal)
+	assert.Equal(t, "orders", *filter.ResourceName)
+	assert.Equal(t, sarama.AclResourceTopic, filter.ResourceType)
+	assert.Equal(t, sarama.AclPatternPrefixed, filter.ResourcePatternTypeFilter)
+}
+
+func TestValidateAclSetBlock(t *testing.T) {
+	block := map[string]interface{}{
+		"resource_type":         "Topic",
+		"resource_name":         "orders",
+		"resource_pattern_type": "Literal",
+		"principal":             "",
+		"host":                  "*",
+		"operation":             "Read",
+		"permission_type":       "Allow",
+	}
+	assert.NoError(t, validateAclSetBlock(principalScope, block))
+	assert.Error(t, validateAclSetBlock(topicScope, block))
+
+	block["principal"] = "User:bob"
+	assert.Error(t, validateAclSetBlock(principalScope, block))
+
+	block["resource_type"] = ""
+	block["resource_name"] = ""
+	assert.NoError(t, validateAclSetBlock(topicScope, block))
+}
+
+func TestAclSetBlockRoundTrip(t *testing.T) {
+	block := map[string]interface{}{
+		"resource_type":         "",
+		"resource_name":         "",
+		"resource_pattern_type": "Literal",
+		"principal":             "User:bob",
+		"host":                  "*",
+		"operation":             "Write",
+		"permission_type":       "Allow",
+	}
+
+	acl, err := expandAclSetBlock(topicScope, block)
+	assert.NoError(t, err)
+	assert.Equal(t, helper.Acl{
+		ResourceType:   sarama.AclResourceTopic,
+		ResourceName:   "orders",
+		PatternType:    sarama.AclPatternPrefixed,
+		Principal:      "User:bob",
+		Host:           "*",
+		Operation:      sarama.AclOperationWrite,
+		PermissionType: sarama.AclPermissionAllow,
+	}, acl)
+
+	assert.Equal(t, block, flattenAclSetBlock(topicScope, acl))
+}
+
+func TestDiffAcls(t *testing.T) {
+	read := groupAcl
+	write := groupAcl
+	write.Operation = sarama.AclOperationWrite
+	describe := groupAcl
+	describe.Operation = sarama.AclOperationDescribe
+
+	missing, unmanaged := diffAcls([]helper.Acl{read, write}, []helper.Acl{read, describe})
+	assert.Equal(t, []helper.Acl{describe}, missing)
+	assert.Equal(t, []helper.Acl{write}, unmanaged)
+
+	missing, unmanaged = diffAcls(nil, nil)
+	assert.Empty(t, missing)
+	assert.Empty(t, unmanaged)
+}
+
+func TestApplyAclSetCreatesBeforeDeleting(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"DescribeAclsRequest": sarama.NewMockListAclsResponse(t),
+		"CreateAclsRequest":   sarama.NewMockCreateAclsResponse(t),
+		"DeleteAclsRequest":   sarama.NewMockDeleteAclsResponse(t),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	acl := resourceKafkaAclSet().Schema["acl"].Elem.(*schema.Resource)
+	blocks := schema.NewSet(schema.HashResource(acl), nil)
+	blocks.Add(map[string]interface{}{
+		"resource_type":         "Topic",
+		"resource_name":         "orders",
+		"resource_pattern_type": "Literal",
+		"principal":             "",
+		"host":                  "*",
+		"operation":             "Read",
+		"permission_type":       "Allow",
+	})
+
+	// the mock lists an ACL which is not in the set
+	err := applyAclSet(newAdminScheduler(1).broker(broker), principalScope, blocks)
+	assert.NoError(t, err)
+
+	var sent []string
+	for _, exchange := range mock.History() {
+		sent = append(sent, fmt.Sprintf("%T", exchange.Request))
+	}
+	assert.Equal(t, []string{"*sarama.DescribeAclsRequest", "*sarama.CreateAclsRequest", "*sarama.DeleteAclsRequest"}, sent)
+}
@@ -0,0 +1,196 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// resourceKafkaBrokerConfig owns every dynamic config of a broker, or the
+// cluster-wide dynamic defaults. AlterConfigs replaces the whole set, so
+// dynamic configs missing from the resource are removed.
+func resourceKafkaBrokerConfig() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKafkaBrokerConfigCreate,
+		Read:   resourceKafkaBrokerConfigRead,
+		Update: resourceKafkaBrokerConfigUpdate,
+		Delete: resourceKafkaBrokerConfigDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceKafkaBrokerConfigImport,
+		},
+		CustomizeDiff: resourceKafkaBrokerConfigCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"broker_id": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "",
+				Description:  "Id of the broker, empty for the cluster-wide defaults",
+				ValidateFunc: validateBrokerID,
+			},
+			"config": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Dynamic configs of the broker, such as log.cleaner.threads",
+			},
+			"sensitive_config": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Dynamic configs Kafka reports as sensitive, such as listener passwords, their values cannot be read back",
+			},
+		},
+	}
+}
+
+// brokerConfigSource is the source of the dynamic configs of the resource,
+// describing a broker also returns the defaults it inherits
+func brokerConfigSource(brokerID string) sarama.ConfigSource {
+	if brokerID == "" {
+		return sarama.SourceDynamicDefaultBroker
+	}
+
+	return sarama.SourceDynamicBroker
+}
+
+// brokerConfigs merges config and sensitive_config into the entries of the
+// AlterConfigs request
+func brokerConfigs(d aclGetter) map[string]interface{} {
+	configs := make(map[string]interface{})
+	for name, value := range d.Get("config").(map[string]interface{}) {
+		configs[name] = value
+	}
+
+	for name, value := range d.Get("sensitive_config").(map[string]interface{}) {
+		configs[name] = value
+	}
+
+	return configs
+}
+
+// flattenBrokerConfigs splits the dynamic entries of a broker between config
+// and sensitive_config. Kafka never returns sensitive values, the ones known
+// from the state are kept and the others are read as empty so that configs
+// set out of band show up in the plan.
+func flattenBrokerConfigs(entries []*sarama.ConfigEntry, source sarama.ConfigSource, sensitive map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	configs := make(map[string]interface{})
+	secrets := make(map[string]interface{})
+
+	for _, entry := range entries {
+		if entry.Source != source {
+			continue
+		}
+
+		if !entry.Sensitive {
+			configs[entry.Name] = entry.Value
+			continue
+		}
+
+		if value, ok := sensitive[entry.Name]; ok {
+			secrets[entry.Name] = value
+		} else {
+			secrets[entry.Name] = ""
+		}
+	}
+
+	return configs, secrets
+}
+
+func resourceKafkaBrokerConfigCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown("config") || !d.NewValueKnown("sensitive_config") {
+		return nil
+	}
+
+	sensitive := d.Get("sensitive_config").(map[string]interface{})
+	for name := range d.Get("config").(map[string]interface{}) {
+		if _, ok := sensitive[name]; ok {
+			return fmt.Errorf("%s is set in both config and sensitive_config", name)
+		}
+	}
+
+	return nil
+}
+
+func resourceKafkaBrokerConfigCreate(d *schema.ResourceData, m interface{}) error {
+	brokerID := d.Get("broker_id").(string)
+
+	err := applyBrokerConfigs(m.(*providerMeta).broker, brokerID, brokerConfigs(d))
+	if err != nil {
+		return err
+	}
+
+	d.SetId(brokerConfigID(brokerID))
+	return resourceKafkaBrokerConfigRead(d, m)
+}
+
+func resourceKafkaBrokerConfigRead(d *schema.ResourceData, m interface{}) error {
+	brokerID, err := parseBrokerConfigID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	broker, done, err := routeToBroker(m.(*providerMeta).broker, brokerID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	entries, err := describeResourceConfigs(broker, sarama.BrokerResource, brokerID, nil)
+	if err != nil {
+		return err
+	}
+
+	configs, secrets := flattenBrokerConfigs(
+		entries,
+		brokerConfigSource(brokerID),
+		d.Get("sensitive_config").(map[string]interface{}),
+	)
+
+	d.Set("broker_id", brokerID)
+	d.Set("config", configs)
+	d.Set("sensitive_config", secrets)
+
+	return nil
+}
+
+func resourceKafkaBrokerConfigUpdate(d *schema.ResourceData, m interface{}) error {
+	err := applyBrokerConfigs(m.(*providerMeta).broker, d.Get("broker_id").(string), brokerConfigs(d))
+	if err != nil {
+		return err
+	}
+
+	return resourceKafkaBrokerConfigRead(d, m)
+}
+
+// resourceKafkaBrokerConfigDelete removes every dynamic config, the broker
+// falls back to the defaults and server.properties
+func resourceKafkaBrokerConfigDelete(d *schema.ResourceData, m interface{}) error {
+	brokerID, err := parseBrokerConfigID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	return applyBrokerConfigs(m.(*providerMeta).broker, brokerID, map[string]interface{}{})
+}
+
+// resourceKafkaBrokerConfigImport accepts a broker id or "default"
+func resourceKafkaBrokerConfigImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	if _, err := parseBrokerConfigID(d.Id()); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+func applyBrokerConfigs(broker *adminBroker, brokerID string, configs map[string]interface{}) error {
+	broker, done, err := routeToBroker(broker, brokerID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	log.Printf("[DEBUG] Kafka: setting %d dynamic config(s) of broker %s", len(configs), brokerConfigID(brokerID))
+	return alterResourceConfigs(broker, sarama.BrokerResource, brokerID, configs)
+}
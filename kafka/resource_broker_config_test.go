@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerConfigID(t *testing.T) {
+	assert.Equal(t, "default", brokerConfigID(""))
+	assert.Equal(t, "1", brokerConfigID("1"))
+
+	brokerID, err := parseBrokerConfigID("default")
+	assert.NoError(t, err)
+	assert.Equal(t, "", brokerID)
+
+	brokerID, err = parseBrokerConfigID("3")
+	assert.NoError(t, err)
+	assert.Equal(t, "3", brokerID)
+
+	_, err = parseBrokerConfigID("broker-3")
+	assert.Error(t, err)
+}
+
+func TestValidateBrokerID(t *testing.T) {
+	_, errs := validateBrokerID("", "broker_id")
+	assert.Empty(t, errs)
+
+	_, errs = validateBrokerID("2", "broker_id")
+	assert.Empty(t, errs)
+
+	_, errs = validateBrokerID("-1", "broker_id")
+	assert.Len(t, errs, 1)
+}
+
+func TestFlattenBrokerConfigs(t *testing.T) {
+	entries := []*sarama.ConfigEntry{
+		{Name: "log.cleaner.threads", Value: "2", Source: sarama.SourceDynamicBroker},
+		{Name: "num.io.threads", Value: "8", Source: sarama.SourceDynamicDefaultBroker},
+		{Name: "log.dirs", Value: "/var/lib/kafka", Source: sarama.SourceStaticBroker},
+		{Name: "listener.name.internal.ssl.key.password", Source: sarama.SourceDynamicBroker, Sensitive: true},
+		{Name: "listener.name.internal.ssl.keystore.password", Source: sarama.SourceDynamicBroker, Sensitive: true},
+	}
+
+	configs, secrets := flattenBrokerConfigs(entries, sarama.SourceDynamicBroker, map[string]interface{}{
+		"listener.name.internal.ssl.key.password": "secret",
+	})
+	assert.Equal(t, map[string]interface{}{"log.cleaner.threads": "2"}, configs)
+	assert.Equal(t, map[string]interface{}{
+		"listener.name.internal.ssl.key.password":      "secret",
+		"listener.name.internal.ssl.keystore.password": "",
+	}, secrets)
+
+	configs, secrets = flattenBrokerConfigs(entries, sarama.SourceDynamicDefaultBroker, nil)
+	assert.Equal(t, map[string]interface{}{"num.io.threads": "8"}, configs)
+	assert.Empty(t, secrets)
+}
+
+func TestRouteToBroker(t *testing.T) {
+	mock, broker := newMockTopicBroker(t)
+	defer mock.Close()
+
+	routed, done, err := routeToBroker(broker, "")
+	assert.NoError(t, err)
+	assert.Equal(t, broker, routed)
+	done()
+
+	routed, done, err = routeToBroker(broker, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, mock.BrokerID(), routed.ID())
+
+	entries, err := describeResourceConfigs(routed, sarama.BrokerResource, "1", nil)
+	done()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "min.insync.replicas", entries[0].Name)
+
+	_, _, err = routeToBroker(broker, "7")
+	assert.EqualError(t, err, "Broker 7 is not part of the cluster")
+}
@@ -0,0 +1,290 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// brokerLoggerAllBrokers stands for every broker of the cluster in the IDs of
+// kafka_broker_logger
+const brokerLoggerAllBrokers = "all"
+
+// brokerRootLogger is the name of the log4j root logger, every logger without
+// a level of its own inherits its level
+const brokerRootLogger = "root"
+
+// Levels accepted by the broker loggers
+var brokerLoggerLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// resourceKafkaBrokerLogger sets the level of a log4j logger through the
+// BROKER_LOGGER config resource. The levels found when the resource is
+// created are restored on destroy.
+func resourceKafkaBrokerLogger() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKafkaBrokerLoggerCreate,
+		Read:   resourceKafkaBrokerLoggerRead,
+		Update: resourceKafkaBrokerLoggerUpdate,
+		Delete: resourceKafkaBrokerLoggerDelete,
+		Schema: map[string]*schema.Schema{
+			"broker_id": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "",
+				Description:  "Id of the broker, empty for every broker of the cluster",
+				ValidateFunc: validateBrokerID,
+			},
+			"logger": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the logger, such as kafka.controller.KafkaController",
+			},
+			"level": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				Description:  "Level of the logger",
+				ValidateFunc: validateAclValue(brokerLoggerLevels),
+			},
+			"previous_levels": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Levels of the logger by broker id before it was changed, restored on destroy",
+			},
+		},
+	}
+}
+
+func brokerLoggerID(brokerID string, logger string) string {
+	if brokerID == "" {
+		brokerID = brokerLoggerAllBrokers
+	}
+
+	return strings.Join([]string{brokerID, logger}, "|")
+}
+
+// parseBrokerLoggerID reverses brokerLoggerID
+func parseBrokerLoggerID(id string) (string, string, error) {
+	parts := strings.SplitN(id, "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("Broker logger ID %q must be broker_id|logger or all|logger", id)
+	}
+
+	if parts[0] == brokerLoggerAllBrokers {
+		return "", parts[1], nil
+	}
+
+	if _, errs := validateBrokerID(parts[0], "broker_id"); len(errs) > 0 {
+		return "", "", errs[0]
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// forEachLoggerBroker calls fn with each broker the logger is set on, in
+// broker id order: the given broker or every broker of the cluster
+func forEachLoggerBroker(broker *adminBroker, brokerID string, fn func(id string, peer *adminBroker) error) error {
+	brokers, err := clusterBrokers(broker)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i].ID() < brokers[j].ID() })
+
+	found := false
+	for _, b := range brokers {
+		id := strconv.Itoa(int(b.ID()))
+		if brokerID != "" && id != brokerID {
+			continue
+		}
+		found = true
+
+		peer, done, err := routeToPeer(broker, b)
+		if err != nil {
+			return err
+		}
+
+		err = fn(id, peer)
+		done()
+		if err != nil {
+			return err
+		}
+	}
+
+	if brokerID != "" && !found {
+		return fmt.Errorf("Broker %s is not part of the cluster", brokerID)
+	}
+
+	return nil
+}
+
+// effectiveLoggerLevel returns the level a logger logs at on a broker and the
+// level of the root logger. Brokers report loggers without a level of their
+// own at the level of the root logger.
+func effectiveLoggerLevel(broker *adminBroker, brokerID string, logger string) (string, string, error) {
+	entries, err := describeResourceConfigs(broker, sarama.BrokerLoggerResource, brokerID, []string{logger, brokerRootLogger})
+	if err != nil {
+		return "", "", err
+	}
+
+	levels := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		levels[entry.Name] = entry.Value
+	}
+
+	root := levels[brokerRootLogger]
+	level, ok := levels[logger]
+	if !ok {
+		level = root
+	}
+
+	return level, root, nil
+}
+
+// loggerLevel returns the level of a logger on a broker, empty when the
+// logger inherits the level of the root logger. A level set to the one of the
+// root logger cannot be told apart and is restored as inherited.
+func loggerLevel(broker *adminBroker, brokerID string, logger string) (string, error) {
+	level, root, err := effectiveLoggerLevel(broker, brokerID, logger)
+	if err != nil {
+		return "", err
+	}
+
+	if logger == brokerRootLogger || level != root {
+		return level, nil
+	}
+
+	return "", nil
+}
+
+// setLoggerLevel changes the level of a logger on a broker, an empty level
+// deletes it so that the logger inherits the level of the root logger
+func setLoggerLevel(broker *adminBroker, brokerID string, logger string, level string) error {
+	var value *string
+	if level != "" {
+		value = &level
+	}
+
+	log.Printf("[DEBUG] Kafka: setting logger %s of broker %s to %q", logger, brokerID, level)
+	return setResourceConfigs(broker, sarama.BrokerLoggerResource, brokerID, map[string]*string{logger: value})
+}
+
+func resourceKafkaBrokerLoggerCreate(d *schema.ResourceData, m interface{}) error {
+	brokerID := d.Get("broker_id").(string)
+	logger := d.Get("logger").(string)
+
+	d.SetId(brokerLoggerID(brokerID, logger))
+	d.Set("previous_levels", map[string]interface{}{})
+
+	err := applyBrokerLogger(d, m.(*providerMeta).broker)
+	if err != nil {
+		// keep the levels already changed so that destroy restores them
+		if len(d.Get("previous_levels").(map[string]interface{})) == 0 {
+			d.SetId("")
+		}
+		return err
+	}
+
+	return resourceKafkaBrokerLoggerRead(d, m)
+}
+
+// resourceKafkaBrokerLoggerRead reports the first level which differs from
+// the configured one, a broker reset out of band shows up in the plan
+func resourceKafkaBrokerLoggerRead(d *schema.ResourceData, m interface{}) error {
+	brokerID, logger, err := parseBrokerLoggerID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	level := d.Get("level").(string)
+	drift := ""
+	err = forEachLoggerBroker(m.(*providerMeta).broker, brokerID, func(id string, peer *adminBroker) error {
+		current, _, err := effectiveLoggerLevel(peer, id, logger)
+		if err != nil {
+			return err
+		}
+
+		if current != level && drift == "" {
+			log.Printf("[WARN] Kafka: logger %s of broker %s is at %q", logger, id, current)
+			drift = current
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if drift != "" {
+		level = drift
+	}
+
+	d.Set("broker_id", brokerID)
+	d.Set("logger", logger)
+	d.Set("level", level)
+
+	return nil
+}
+
+func resourceKafkaBrokerLoggerUpdate(d *schema.ResourceData, m interface{}) error {
+	err := applyBrokerLogger(d, m.(*providerMeta).broker)
+	if err != nil {
+		return err
+	}
+
+	return resourceKafkaBrokerLoggerRead(d, m)
+}
+
+// resourceKafkaBrokerLoggerDelete restores the previous level of every broker
+// the logger was changed on
+func resourceKafkaBrokerLoggerDelete(d *schema.ResourceData, m interface{}) error {
+	brokerID, logger, err := parseBrokerLoggerID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	previous := d.Get("previous_levels").(map[string]interface{})
+	return forEachLoggerBroker(m.(*providerMeta).broker, brokerID, func(id string, peer *adminBroker) error {
+		level, ok := previous[id]
+		if !ok {
+			return nil
+		}
+
+		return setLoggerLevel(peer, id, logger, level.(string))
+	})
+}
+
+// applyBrokerLogger sets the level on the brokers of the resource, recording
+// the level of brokers changed for the first time. Brokers which joined the
+// cluster since creation are covered as well.
+func applyBrokerLogger(d *schema.ResourceData, broker *adminBroker) error {
+	brokerID := d.Get("broker_id").(string)
+	logger := d.Get("logger").(string)
+	level := d.Get("level").(string)
+
+	previous := make(map[string]interface{})
+	for id, value := range d.Get("previous_levels").(map[string]interface{}) {
+		previous[id] = value
+	}
+
+	err := forEachLoggerBroker(broker, brokerID, func(id string, peer *adminBroker) error {
+		if _, ok := previous[id]; !ok {
+			current, err := loggerLevel(peer, id, logger)
+			if err != nil {
+				return err
+			}
+
+			previous[id] = current
+		}
+
+		return setLoggerLevel(peer, id, logger, level)
+	})
+
+	d.Set("previous_levels", previous)
+	return err
+}
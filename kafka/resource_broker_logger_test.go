@@ -0,0 +1,111 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerLoggerID(t *testing.T) {
+	assert.Equal(t, "all|kafka.controller", brokerLoggerID("", "kafka.controller"))
+	assert.Equal(t, "2|kafka.controller", brokerLoggerID("2", "kafka.controller"))
+
+	brokerID, logger, err := parseBrokerLoggerID("all|kafka.controller")
+	assert.NoError(t, err)
+	assert.Equal(t, "", brokerID)
+	assert.Equal(t, "kafka.controller", logger)
+
+	brokerID, logger, err = parseBrokerLoggerID("2|kafka.controller")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", brokerID)
+	assert.Equal(t, "kafka.controller", logger)
+
+	_, _, err = parseBrokerLoggerID("kafka.controller")
+	assert.Error(t, err)
+
+	_, _, err = parseBrokerLoggerID("broker-2|kafka.controller")
+	assert.Error(t, err)
+}
+
+func TestForEachLoggerBroker(t *testing.T) {
+	mock, broker := newMockTopicBroker(t)
+	defer mock.Close()
+
+	levels := make(map[string]string)
+	err := forEachLoggerBroker(broker, "", func(id string, peer *adminBroker) error {
+		level, err := loggerLevel(peer, id, "kafka.controller.KafkaController")
+		levels[id] = level
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"1": "DEBUG"}, levels)
+
+	err = forEachLoggerBroker(broker, "7", func(id string, peer *adminBroker) error {
+		t.Fatal("unexpected broker", id)
+		return nil
+	})
+	assert.EqualError(t, err, "Broker 7 is not part of the cluster")
+}
+
+func TestLoggerLevelInherited(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+
+	// brokers report inherited loggers at the level of the root logger
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"DescribeConfigsRequest": sarama.NewMockWrapper(&sarama.DescribeConfigsResponse{
+			Version: 1,
+			Resources: []*sarama.ResourceResponse{{
+				Type: sarama.BrokerLoggerResource,
+				Name: "1",
+				Configs: []*sarama.ConfigEntry{
+					{Name: "root", Value: "INFO"},
+					{Name: "kafka.log.LogCleaner", Value: "INFO"},
+					{Name: "kafka.controller.KafkaController", Value: "DEBUG"},
+				},
+			}},
+		}),
+	})
+
+	b := sarama.NewBroker(mock.Addr())
+	if err := openBroker(b); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	broker := newAdminScheduler(1).broker(b)
+
+	level, err := loggerLevel(broker, "1", "kafka.log.LogCleaner")
+	assert.NoError(t, err)
+	assert.Equal(t, "", level)
+
+	level, err = loggerLevel(broker, "1", "kafka.controller.KafkaController")
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG", level)
+
+	// Read compares the configured level with the one logged at
+	level, root, err := effectiveLoggerLevel(broker, "1", "kafka.log.LogCleaner")
+	assert.NoError(t, err)
+	assert.Equal(t, "INFO", level)
+	assert.Equal(t, "INFO", root)
+}
+
+func TestSetLoggerLevelInherited(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"IncrementalAlterConfigsRequest": sarama.NewMockIncrementalAlterConfigsResponse(t),
+	})
+
+	b := sarama.NewBroker(mock.Addr())
+	if err := openBroker(b); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	assert.NoError(t, setLoggerLevel(newAdminScheduler(1).broker(b), "1", "kafka.log.LogCleaner", ""))
+
+	request := mock.History()[len(mock.History())-1].Request.(*sarama.IncrementalAlterConfigsRequest)
+	entry := request.Resources[0].ConfigEntries["kafka.log.LogCleaner"]
+	assert.Equal(t, sarama.IncrementalAlterConfigsOperationDelete, entry.Operation)
+}
@@ -0,0 +1,438 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// quotaDefaultEntity names the default entity of a type, as kafka-configs.sh
+// prints it
+const quotaDefaultEntity = "<default>"
+
+// quotaUnset is the value of quota keys which are not set. Terraform reads a
+// key removed from the configuration as 0 while applying, which is a valid
+// quota, so unset keys default to this value instead.
+const quotaUnset = -1
+
+// Quota keys, named as in Kafka. IPs only take connection_creation_rate,
+// users and client ids take the others.
+var (
+	clientQuotaKeys = []string{"consumer_byte_rate", "producer_byte_rate", "request_percentage"}
+	ipQuotaKeys     = []string{"connection_creation_rate"}
+)
+
+func resourceKafkaQuota() *schema.Resource {
+	quota := &schema.Resource{
+		Create: resourceKafkaQuotaCreate,
+		Read:   resourceKafkaQuotaRead,
+		Update: resourceKafkaQuotaUpdate,
+		Delete: resourceKafkaQuotaDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceKafkaQuotaImport,
+		},
+		CustomizeDiff: resourceKafkaQuotaCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"user": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				Description:   "User the quota applies to, <default> for the default user",
+				ConflictsWith: []string{"ip"},
+			},
+			"client_id": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				Description:   "Client id the quota applies to, <default> for the default client id",
+				ConflictsWith: []string{"ip"},
+			},
+			"ip": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "IP the quota applies to, <default> for the default IP",
+			},
+		},
+	}
+
+	for key, value := range quotaSchema() {
+		quota.Schema[key] = value
+	}
+
+	return quota
+}
+
+// quotaSchema describes the quota values, shared with kafka_service_account
+func quotaSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"producer_byte_rate": &schema.Schema{
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      quotaUnset,
+			Description:  "Bytes per second produced by each broker, -1 when not set",
+			ValidateFunc: validateQuotaValue,
+		},
+		"consumer_byte_rate": &schema.Schema{
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      quotaUnset,
+			Description:  "Bytes per second fetched from each broker, -1 when not set",
+			ValidateFunc: validateQuotaValue,
+		},
+		"request_percentage": &schema.Schema{
+			Type:         schema.TypeFloat,
+			Optional:     true,
+			Default:      float64(quotaUnset),
+			Description:  "Percentage of the request handler and network threads time on each broker, -1 when not set",
+			ValidateFunc: validateQuotaValue,
+		},
+		"connection_creation_rate": &schema.Schema{
+			Type:         schema.TypeFloat,
+			Optional:     true,
+			Default:      float64(quotaUnset),
+			Description:  "Connections per second accepted from the IP by each broker, -1 when not set",
+			ValidateFunc: validateQuotaValue,
+		},
+	}
+}
+
+// quotaValue converts the value of a quota key to the float of Kafka
+func quotaValue(v interface{}) float64 {
+	switch value := v.(type) {
+	case int:
+		return float64(value)
+	case float64:
+		return value
+	}
+
+	return quotaUnset
+}
+
+func validateQuotaValue(v interface{}, k string) (ws []string, errors []error) {
+	if value := quotaValue(v); value < 0 && value != quotaUnset {
+		errors = append(errors, fmt.Errorf("%s must be positive, or %d to leave it unset, got %v", k, quotaUnset, v))
+	}
+	return
+}
+
+// quotaEntityTypes lists the entity attributes in the order of the IDs
+var quotaEntityTypes = []struct {
+	key        string
+	entityType sarama.QuotaEntityType
+}{
+	{"user", sarama.QuotaEntityUser},
+	{"client_id", sarama.QuotaEntityClientID},
+	{"ip", sarama.QuotaEntityIP},
+}
+
+// quotaEntityComponent matches name exactly, or the default entity
+func quotaEntityComponent(entityType sarama.QuotaEntityType, name string) sarama.QuotaEntityComponent {
+	if name == quotaDefaultEntity {
+		return sarama.QuotaEntityComponent{EntityType: entityType, MatchType: sarama.QuotaMatchDefault}
+	}
+
+	return sarama.QuotaEntityComponent{EntityType: entityType, MatchType: sarama.QuotaMatchExact, Name: name}
+}
+
+// expandQuotaEntity reads the entity of a quota: a user, a client id, a user
+// and a client id, or an IP
+func expandQuotaEntity(d aclGetter) ([]sarama.QuotaEntityComponent, error) {
+	var entity []sarama.QuotaEntityComponent
+	for _, t := range quotaEntityTypes {
+		if name := d.Get(t.key).(string); name != "" {
+			entity = append(entity, quotaEntityComponent(t.entityType, name))
+		}
+	}
+
+	if len(entity) == 0 {
+		return nil, fmt.Errorf("A quota applies to a user, a client_id, both, or an ip")
+	}
+
+	return entity, nil
+}
+
+// quotaEntityName returns the attribute value of a component
+func quotaEntityName(component sarama.QuotaEntityComponent) string {
+	if component.MatchType == sarama.QuotaMatchDefault {
+		return quotaDefaultEntity
+	}
+
+	return component.Name
+}
+
+// quotaID joins the components of the entity, such as user=alice|client-id=app
+func quotaID(entity []sarama.QuotaEntityComponent) string {
+	parts := make([]string, 0, len(entity))
+	for _, component := range entity {
+		parts = append(parts, fmt.Sprintf("%s=%s", component.EntityType, quotaEntityName(component)))
+	}
+
+	return strings.Join(parts, "|")
+}
+
+// parseQuotaID reverses quotaID
+func parseQuotaID(id string) ([]sarama.QuotaEntityComponent, error) {
+	names := make(map[string]string)
+	for _, part := range strings.Split(id, "|") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 || pair[1] == "" {
+			return nil, fmt.Errorf("Quota ID %q must be user=<name>, client-id=<name>, user=<name>|client-id=<name> or ip=<address>", id)
+		}
+		names[strings.Replace(pair[0], "-", "_", -1)] = pair[1]
+	}
+
+	var entity []sarama.QuotaEntityComponent
+	for _, t := range quotaEntityTypes {
+		if name, ok := names[t.key]; ok {
+			entity = append(entity, quotaEntityComponent(t.entityType, name))
+			delete(names, t.key)
+		}
+	}
+
+	if len(names) > 0 || len(entity) == 0 || !validQuotaEntity(entity) {
+		return nil, fmt.Errorf("Quota ID %q must be user=<name>, client-id=<name>, user=<name>|client-id=<name> or ip=<address>", id)
+	}
+
+	return entity, nil
+}
+
+func validQuotaEntity(entity []sarama.QuotaEntityComponent) bool {
+	for _, component := range entity {
+		if component.EntityType == sarama.QuotaEntityIP {
+			return len(entity) == 1
+		}
+	}
+
+	return true
+}
+
+// quotaKeys returns the keys which apply to the entity
+func quotaKeys(entity []sarama.QuotaEntityComponent) []string {
+	if entity[0].EntityType == sarama.QuotaEntityIP {
+		return ipQuotaKeys
+	}
+
+	return clientQuotaKeys
+}
+
+// sameQuotaEntity compares entities regardless of the order of the components
+func sameQuotaEntity(a, b []sarama.QuotaEntityComponent) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x.EntityType == y.EntityType && x.MatchType == y.MatchType && x.Name == y.Name {
+				found = true
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// expandQuotaValues returns the values set on the resource and the keys of
+// the entity left unset, which are removed from the cluster
+func expandQuotaValues(d aclGetter, entity []sarama.QuotaEntityComponent) (map[string]float64, []string) {
+	values := make(map[string]float64)
+	var removed []string
+	for _, key := range quotaKeys(entity) {
+		value := quotaValue(d.Get(key))
+		if value == quotaUnset {
+			removed = append(removed, key)
+			continue
+		}
+
+		values[key] = value
+	}
+
+	return values, removed
+}
+
+// flattenQuotaValues converts the values of Kafka to the types of the schema,
+// keys missing from values are unset
+func flattenQuotaValues(values map[string]float64) map[string]interface{} {
+	flat := map[string]interface{}{
+		"producer_byte_rate":       quotaUnset,
+		"consumer_byte_rate":       quotaUnset,
+		"request_percentage":       float64(quotaUnset),
+		"connection_creation_rate": float64(quotaUnset),
+	}
+
+	for key, value := range values {
+		switch key {
+		case "producer_byte_rate", "consumer_byte_rate":
+			flat[key] = int(value)
+		case "request_percentage", "connection_creation_rate":
+			flat[key] = value
+		default:
+			log.Printf("[DEBUG] Kafka: ignoring quota %s", key)
+		}
+	}
+
+	return flat
+}
+
+func resourceKafkaQuotaCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	for _, t := range quotaEntityTypes {
+		if !d.NewValueKnown(t.key) {
+			return nil
+		}
+	}
+
+	entity, err := expandQuotaEntity(d)
+	if err != nil {
+		return err
+	}
+
+	allowed := make(map[string]bool)
+	for _, key := range quotaKeys(entity) {
+		allowed[key] = true
+	}
+
+	for _, key := range append(append([]string{}, clientQuotaKeys...), ipQuotaKeys...) {
+		if quotaValue(d.Get(key)) != quotaUnset && !allowed[key] {
+			return fmt.Errorf("%s does not apply to %s", key, quotaID(entity))
+		}
+	}
+
+	return nil
+}
+
+func resourceKafkaQuotaCreate(d *schema.ResourceData, m interface{}) error {
+	entity, err := expandQuotaEntity(d)
+	if err != nil {
+		return err
+	}
+
+	values, removed := expandQuotaValues(d, entity)
+	err = alterQuota(m.(*providerMeta).broker, entity, values, removed)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(quotaID(entity))
+	return resourceKafkaQuotaRead(d, m)
+}
+
+func resourceKafkaQuotaRead(d *schema.ResourceData, m interface{}) error {
+	entity, err := parseQuotaID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	values, err := describeQuota(m.(*providerMeta).broker, entity)
+	if err != nil {
+		return err
+	}
+
+	// removed out of band, the next plan creates it again
+	if len(values) == 0 {
+		log.Printf("[WARN] Kafka: quota %s no longer exists, removing it from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	for _, t := range quotaEntityTypes {
+		d.Set(t.key, "")
+	}
+	for _, component := range entity {
+		for _, t := range quotaEntityTypes {
+			if t.entityType == component.EntityType {
+				d.Set(t.key, quotaEntityName(component))
+			}
+		}
+	}
+
+	for key, value := range flattenQuotaValues(values) {
+		d.Set(key, value)
+	}
+
+	return nil
+}
+
+func resourceKafkaQuotaUpdate(d *schema.ResourceData, m interface{}) error {
+	entity, err := parseQuotaID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	values, removed := expandQuotaValues(d, entity)
+	err = alterQuota(m.(*providerMeta).broker, entity, values, removed)
+	if err != nil {
+		return err
+	}
+
+	return resourceKafkaQuotaRead(d, m)
+}
+
+// resourceKafkaQuotaDelete removes every quota of the entity, which falls
+// back to the default entity and the broker configs
+func resourceKafkaQuotaDelete(d *schema.ResourceData, m interface{}) error {
+	entity, err := parseQuotaID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	return alterQuota(m.(*providerMeta).broker, entity, nil, quotaKeys(entity))
+}
+
+// resourceKafkaQuotaImport accepts the ID of the quota, see quotaID
+func resourceKafkaQuotaImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	entity, err := parseQuotaID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(quotaID(entity))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// describeQuota returns the quotas of exactly this entity
+func describeQuota(broker *adminBroker, entity []sarama.QuotaEntityComponent) (map[string]float64, error) {
+	response, err := broker.DescribeClientQuotas(r.DescribeKafkaClientQuotasRequest(entity))
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	if response.ErrorCode != sarama.ErrNoError {
+		return nil, kafkaError(response.ErrorCode, response.ErrorMsg)
+	}
+
+	// strict filters still match the entity regardless of the order
+	for _, entry := range response.Entries {
+		if sameQuotaEntity(entry.Entity, entity) {
+			return entry.Values, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func alterQuota(broker *adminBroker, entity []sarama.QuotaEntityComponent, values map[string]float64, removed []string) error {
+	response, err := broker.AlterClientQuotas(r.AlterKafkaClientQuotasRequest(entity, values, removed))
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	for _, entry := range response.Entries {
+		if entry.ErrorCode != sarama.ErrNoError {
+			return fmt.Errorf("Error altering quota %s: %s", quotaID(entity), kafkaError(entry.ErrorCode, entry.ErrorMsg))
+		}
+	}
+
+	return nil
+}
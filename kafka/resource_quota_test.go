@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaID(t *testing.T) {
+	entity, err := expandQuotaEntity(aclBlock{"user": "alice", "client_id": "<default>", "ip": ""})
+	assert.NoError(t, err)
+	assert.Equal(t, []sarama.QuotaEntityComponent{
+		{EntityType: sarama.QuotaEntityUser, MatchType: sarama.QuotaMatchExact, Name: "alice"},
+		{EntityType: sarama.QuotaEntityClientID, MatchType: sarama.QuotaMatchDefault},
+	}, entity)
+	assert.Equal(t, "user=alice|client-id=<default>", quotaID(entity))
+
+	parsed, err := parseQuotaID("client-id=<default>|user=alice")
+	assert.NoError(t, err)
+	assert.Equal(t, entity, parsed)
+
+	parsed, err = parseQuotaID("ip=10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, ipQuotaKeys, quotaKeys(parsed))
+
+	_, err = expandQuotaEntity(aclBlock{"user": "", "client_id": "", "ip": ""})
+	assert.Error(t, err)
+
+	for _, id := range []string{"alice", "user=", "group=billing", "ip=10.0.0.1|user=alice"} {
+		_, err = parseQuotaID(id)
+		assert.Error(t, err, id)
+	}
+}
+
+func TestSameQuotaEntity(t *testing.T) {
+	entity, _ := parseQuotaID("user=alice|client-id=app")
+	reversed := []sarama.QuotaEntityComponent{entity[1], entity[0]}
+	assert.True(t, sameQuotaEntity(entity, reversed))
+
+	other, _ := parseQuotaID("user=alice")
+	assert.False(t, sameQuotaEntity(entity, other))
+
+	defaultUser, _ := parseQuotaID("user=<default>")
+	assert.False(t, sameQuotaEntity(other, defaultUser))
+}
+
+func TestFlattenQuotaValues(t *testing.T) {
+	flat := flattenQuotaValues(map[string]float64{
+		"producer_byte_rate":       1048576,
+		"request_percentage":       12.5,
+		"controller_mutation_rate": 5,
+	})
+
+	assert.Equal(t, 1048576, flat["producer_byte_rate"])
+	assert.Equal(t, 12.5, flat["request_percentage"])
+	assert.Equal(t, quotaUnset, flat["consumer_byte_rate"])
+	assert.NotContains(t, flat, "controller_mutation_rate")
+}
+
+func TestQuotaUpdateRemovesUnsetKeys(t *testing.T) {
+	entity := []sarama.QuotaEntityComponent{quotaEntityComponent(sarama.QuotaEntityUser, "alice")}
+
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"AlterClientQuotasRequest": sarama.NewMockWrapper(&sarama.AlterClientQuotasResponse{
+			Entries: []sarama.AlterClientQuotasEntryResponse{{Entity: entity}},
+		}),
+		"DescribeClientQuotasRequest": sarama.NewMockWrapper(&sarama.DescribeClientQuotasResponse{
+			Entries: []sarama.DescribeClientQuotasEntry{{
+				Entity: entity,
+				Values: map[string]float64{"consumer_byte_rate": 0},
+			}},
+		}),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	meta := &providerMeta{broker: newAdminScheduler(1).broker(broker)}
+
+	// producer_byte_rate is removed from the configuration and
+	// consumer_byte_rate is set to 0, which blocks the user
+	state, err := resourceKafkaQuota().Apply(&terraform.InstanceState{
+		ID: "user=alice",
+		Attributes: map[string]string{
+			"user":                     "alice",
+			"producer_byte_rate":       "100",
+			"consumer_byte_rate":       "-1",
+			"request_percentage":       "-1",
+			"connection_creation_rate": "-1",
+		},
+	}, &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"producer_byte_rate": {Old: "100", New: "-1"},
+			"consumer_byte_rate": {Old: "-1", New: "0"},
+		},
+	}, meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "-1", state.Attributes["producer_byte_rate"])
+	assert.Equal(t, "0", state.Attributes["consumer_byte_rate"])
+
+	var request *sarama.AlterClientQuotasRequest
+	for _, exchange := range mock.History() {
+		if altered, ok := exchange.Request.(*sarama.AlterClientQuotasRequest); ok {
+			request = altered
+		}
+	}
+	if assert.NotNil(t, request) && assert.Len(t, request.Entries, 1) {
+		ops := make(map[string]sarama.ClientQuotasOp)
+		for _, op := range request.Entries[0].Ops {
+			ops[op.Key] = op
+		}
+		assert.True(t, ops["producer_byte_rate"].Remove)
+		assert.True(t, ops["request_percentage"].Remove)
+		assert.False(t, ops["consumer_byte_rate"].Remove)
+		assert.Equal(t, float64(0), ops["consumer_byte_rate"].Value)
+	}
+}
+
+func TestValidateQuotaValue(t *testing.T) {
+	_, errs := validateQuotaValue(quotaUnset, "producer_byte_rate")
+	assert.Empty(t, errs)
+
+	_, errs = validateQuotaValue(0.0, "request_percentage")
+	assert.Empty(t, errs)
+
+	_, errs = validateQuotaValue(-2, "producer_byte_rate")
+	assert.Len(t, errs, 1)
+
+	// kafka_service_account shares the schema of the quota values
+	account := resourceKafkaServiceAccount().Schema
+	assert.Equal(t, quotaUnset, account["producer_byte_rate"].Default)
+}
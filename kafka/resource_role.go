@@ -0,0 +1,232 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Placeholders of the role resource names, replaced by the values of the
+// binding
+const (
+	roleTopicPrefixPlaceholder = "{topic_prefix}"
+	roleGroupPlaceholder       = "{group}"
+)
+
+// resourceKafkaRole only lives in the state: Kafka has no notion of roles, the
+// definition is handed to kafka_role_binding which creates the ACLs
+func resourceKafkaRole() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceKafkaRoleCreate,
+		Read:          resourceKafkaRoleRead,
+		Update:        resourceKafkaRoleUpdate,
+		Delete:        resourceKafkaRoleDelete,
+		CustomizeDiff: resourceKafkaRoleCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the role, such as stream-app",
+			},
+			"acl": &schema.Schema{
+				Type:        schema.TypeSet,
+				Required:    true,
+				Description: "ACLs granted to the principal of each binding, resource names may contain {topic_prefix} and {group}",
+				Elem:        aclTemplateResource(),
+			},
+			"definition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Encoded role, passed to the role attribute of kafka_role_binding",
+			},
+		},
+	}
+}
+
+// aclTemplateResource describes an ACL block without principal, the
+// principal comes from the resource
+func aclTemplateResource() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"resource_type": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateAclValue(aclResourceTypes),
+			},
+			"resource_name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"resource_pattern_type": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "Literal",
+				ValidateFunc: validateAclValue(aclPatternTypes),
+			},
+			"host": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "*",
+			},
+			"operation": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateAclValue(aclOperations),
+			},
+			"permission_type": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "Allow",
+				ValidateFunc: validateAclValue(aclPermissionTypes),
+			},
+		},
+	}
+}
+
+// roleDefinition is the encoded form of a role shared with its bindings
+type roleDefinition struct {
+	Name string            `json:"name"`
+	Acls []roleAclTemplate `json:"acls"`
+}
+
+// roleAclTemplate is an ACL without principal, its resource name may contain
+// placeholders
+type roleAclTemplate struct {
+	ResourceType   string `json:"resource_type"`
+	ResourceName   string `json:"resource_name"`
+	PatternType    string `json:"resource_pattern_type"`
+	Host           string `json:"host"`
+	Operation      string `json:"operation"`
+	PermissionType string `json:"permission_type"`
+}
+
+func expandRoleDefinition(d aclGetter) roleDefinition {
+	role := roleDefinition{Name: d.Get("name").(string)}
+	for _, item := range d.Get("acl").(*schema.Set).List() {
+		block := item.(map[string]interface{})
+		role.Acls = append(role.Acls, roleAclTemplate{
+			ResourceType:   block["resource_type"].(string),
+			ResourceName:   block["resource_name"].(string),
+			PatternType:    block["resource_pattern_type"].(string),
+			Host:           block["host"].(string),
+			Operation:      block["operation"].(string),
+			PermissionType: block["permission_type"].(string),
+		})
+	}
+
+	// the encoded role must not depend on the order of the set
+	sort.Slice(role.Acls, func(i, j int) bool {
+		return role.Acls[i].key() < role.Acls[j].key()
+	})
+
+	return role
+}
+
+func (t roleAclTemplate) key() string {
+	return strings.Join([]string{t.ResourceType, t.PatternType, t.Host, t.Operation, t.PermissionType, t.ResourceName}, aclIDSeparator)
+}
+
+func (r roleDefinition) encode() (string, error) {
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+func decodeRoleDefinition(encoded string) (roleDefinition, error) {
+	var role roleDefinition
+	if err := json.Unmarshal([]byte(encoded), &role); err != nil {
+		return role, fmt.Errorf("Role must be the definition of a kafka_role: %s", err)
+	}
+
+	return role, nil
+}
+
+// bind expands the role into the ACLs of a principal, placeholders without a
+// value are refused rather than granting a literal "{group}"
+func (r roleDefinition) bind(principal string, topicPrefix string, group string) ([]helper.Acl, error) {
+	values := map[string]string{
+		roleTopicPrefixPlaceholder: topicPrefix,
+		roleGroupPlaceholder:       group,
+	}
+
+	generated := make(map[helper.Acl]bool)
+	for _, template := range r.Acls {
+		name := template.ResourceName
+		for placeholder, value := range values {
+			if !strings.Contains(name, placeholder) {
+				continue
+			}
+
+			if value == "" {
+				return nil, fmt.Errorf("Role %s uses %s in %q, the binding must set it", r.Name, placeholder, template.ResourceName)
+			}
+
+			name = strings.Replace(name, placeholder, value, -1)
+		}
+
+		acl, err := newAcl(template.ResourceType, name, template.PatternType, principal, template.Host, template.Operation, template.PermissionType)
+		if err != nil {
+			return nil, err
+		}
+
+		generated[acl] = true
+	}
+
+	acls := make([]helper.Acl, 0, len(generated))
+	for acl := range generated {
+		acls = append(acls, acl)
+	}
+	sortAcls(acls)
+
+	return acls, nil
+}
+
+func resourceKafkaRoleCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown("name") || !d.NewValueKnown("acl") {
+		return d.SetNewComputed("definition")
+	}
+
+	definition, err := expandRoleDefinition(d).encode()
+	if err != nil {
+		return err
+	}
+
+	if d.Get("definition").(string) == definition {
+		return nil
+	}
+
+	return d.SetNew("definition", definition)
+}
+
+func resourceKafkaRoleCreate(d *schema.ResourceData, m interface{}) error {
+	d.SetId(d.Get("name").(string))
+	return resourceKafkaRoleRead(d, m)
+}
+
+func resourceKafkaRoleRead(d *schema.ResourceData, m interface{}) error {
+	definition, err := expandRoleDefinition(d).encode()
+	if err != nil {
+		return err
+	}
+
+	d.Set("definition", definition)
+	return nil
+}
+
+func resourceKafkaRoleUpdate(d *schema.ResourceData, m interface{}) error {
+	return resourceKafkaRoleRead(d, m)
+}
+
+// resourceKafkaRoleDelete leaves the ACLs alone, they belong to the bindings
+func resourceKafkaRoleDelete(d *schema.ResourceData, m interface{}) error {
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,219 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceKafkaRoleBinding() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceKafkaRoleBindingCreate,
+		Read:          resourceKafkaRoleBindingRead,
+		Update:        resourceKafkaRoleBindingUpdate,
+		Delete:        resourceKafkaRoleBindingDelete,
+		CustomizeDiff: resourceKafkaRoleBindingCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"role": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Definition of the kafka_role to apply",
+			},
+			"principal": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Principal the role is granted to, such as User:alice",
+			},
+			"topic_prefix": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Value of {topic_prefix} in the role",
+			},
+			"group": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Value of {group} in the role",
+			},
+			"acls": ownedAclsSchema("IDs of the ACLs the binding generated from the role, which cannot be granted by other resources since they are deleted with the binding"),
+		},
+	}
+}
+
+// roleBindingAcls expands the role of the binding
+func roleBindingAcls(d aclGetter) ([]helper.Acl, error) {
+	role, err := decodeRoleDefinition(d.Get("role").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return role.bind(d.Get("principal").(string), d.Get("topic_prefix").(string), d.Get("group").(string))
+}
+
+// roleBindingID joins the role name and the principal
+func roleBindingID(d aclGetter) (string, error) {
+	role, err := decodeRoleDefinition(d.Get("role").(string))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{role.Name, d.Get("principal").(string)}, aclIDSeparator), nil
+}
+
+func resourceKafkaRoleBindingCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	for _, key := range []string{"role", "principal", "topic_prefix", "group"} {
+		if !d.NewValueKnown(key) {
+			return d.SetNewComputed("acls")
+		}
+	}
+
+	desired, err := roleBindingAcls(d)
+	if err != nil {
+		return err
+	}
+
+	owned, err := parseAclIDs(d.Get("acls").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	err = ensureAclsUnclaimed(m.(*providerMeta).broker, "role binding", desired, owned)
+	if err != nil {
+		return err
+	}
+
+	return customizeDiffOwnedAcls(d, desired)
+}
+
+// ensureAclsUnclaimed refuses desired ACLs which already exist without being
+// owned by the resource, a role binding or a service account. They are
+// granted by another resource, a kafka_topic, a kafka_acl or another owner,
+// or out of band, and an owner deletes every ACL it owns on destroy.
+func ensureAclsUnclaimed(broker *adminBroker, owner string, desired []helper.Acl, owned []helper.Acl) error {
+	candidates, _ := diffAcls(owned, desired)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	existing, err := existingAcls(broker, candidates)
+	if err != nil {
+		return err
+	}
+
+	var claimed []helper.Acl
+	for _, acl := range candidates {
+		if existing[acl] {
+			claimed = append(claimed, acl)
+		}
+	}
+
+	if len(claimed) > 0 {
+		return fmt.Errorf(
+			"ACLs %s already exist, a %s cannot share ACLs with other resources since it deletes them on destroy",
+			strings.Join(aclIDs(claimed), ", "),
+			owner,
+		)
+	}
+
+	return nil
+}
+
+func resourceKafkaRoleBindingCreate(d *schema.ResourceData, m interface{}) error {
+	id, err := roleBindingID(d)
+	if err != nil {
+		return err
+	}
+
+	err = applyRoleBinding(d, m.(*providerMeta).broker)
+	if err != nil {
+		// keep what was created so that it is released on destroy
+		if d.Get("acls").(*schema.Set).Len() > 0 {
+			d.SetId(id)
+		}
+		return err
+	}
+
+	d.SetId(id)
+	return resourceKafkaRoleBindingRead(d, m)
+}
+
+func resourceKafkaRoleBindingRead(d *schema.ResourceData, m interface{}) error {
+	acls, err := readOwnedAcls(m.(*providerMeta).broker, d.Get("acls").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	d.Set("acls", acls)
+	return nil
+}
+
+func resourceKafkaRoleBindingUpdate(d *schema.ResourceData, m interface{}) error {
+	id, err := roleBindingID(d)
+	if err != nil {
+		return err
+	}
+
+	err = applyRoleBinding(d, m.(*providerMeta).broker)
+	if err != nil {
+		return err
+	}
+
+	// the binding follows a renamed role
+	d.SetId(id)
+	return resourceKafkaRoleBindingRead(d, m)
+}
+
+func resourceKafkaRoleBindingDelete(d *schema.ResourceData, m interface{}) error {
+	owned, err := parseAclIDs(d.Get("acls").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Kafka: releasing %d ACL(s) of role binding %s", len(owned), d.Id())
+	return deleteAcls(m.(*providerMeta).broker, owned)
+}
+
+// applyRoleBinding creates the ACLs of the role and deletes the ACLs the
+// binding owned which the role no longer grants
+func applyRoleBinding(d *schema.ResourceData, broker *adminBroker) error {
+	desired, err := roleBindingAcls(d)
+	if err != nil {
+		return err
+	}
+
+	// bindings planned together may grant the same ACLs
+	oldVal, _ := d.GetChange("acls")
+	owned, err := parseAclIDs(oldVal.(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	err = ensureAclsUnclaimed(broker, "role binding", desired, owned)
+	if err != nil {
+		return err
+	}
+
+	err = applyOwnedAcls(d, broker, desired, func(released []helper.Acl) ([]helper.Acl, error) {
+		return nil, deleteAcls(broker, released)
+	})
+	if err != nil {
+		// none of the desired ACLs existed unowned, those created before
+		// the failure belong to the binding
+		created, readErr := existingAcls(broker, desired)
+		if readErr == nil {
+			acls := d.Get("acls").(*schema.Set)
+			for _, acl := range desired {
+				if created[acl] {
+					acls.Add(aclID(acl))
+				}
+			}
+			d.Set("acls", acls)
+		}
+		return err
+	}
+
+	return nil
+}
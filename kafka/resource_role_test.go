@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+var streamApp = roleDefinition{
+	Name: "stream-app",
+	Acls: []roleAclTemplate{
+		{ResourceType: "Topic", ResourceName: "{topic_prefix}", PatternType: "Prefixed", Host: "*", Operation: "All", PermissionType: "Allow"},
+		{ResourceType: "Group", ResourceName: "{group}", PatternType: "Literal", Host: "*", Operation: "Read", PermissionType: "Allow"},
+		{ResourceType: "Cluster", ResourceName: "kafka-cluster", PatternType: "Literal", Host: "*", Operation: "IdempotentWrite", PermissionType: "Allow"},
+	},
+}
+
+func TestRoleDefinitionEncoding(t *testing.T) {
+	block := func(template roleAclTemplate) interface{} {
+		return map[string]interface{}{
+			"resource_type":         template.ResourceType,
+			"resource_name":         template.ResourceName,
+			"resource_pattern_type": template.PatternType,
+			"host":                  template.Host,
+			"operation":             template.Operation,
+			"permission_type":       template.PermissionType,
+		}
+	}
+
+	hash := func(v interface{}) int {
+		return schema.HashString(v.(map[string]interface{})["resource_name"])
+	}
+	first := expandRoleDefinition(aclBlock{
+		"name": "stream-app",
+		"acl":  schema.NewSet(hash, []interface{}{block(streamApp.Acls[0]), block(streamApp.Acls[1]), block(streamApp.Acls[2])}),
+	})
+	second := expandRoleDefinition(aclBlock{
+		"name": "stream-app",
+		"acl":  schema.NewSet(hash, []interface{}{block(streamApp.Acls[2]), block(streamApp.Acls[0]), block(streamApp.Acls[1])}),
+	})
+
+	encoded, err := first.encode()
+	assert.NoError(t, err)
+	other, err := second.encode()
+	assert.NoError(t, err)
+	assert.Equal(t, encoded, other)
+
+	decoded, err := decodeRoleDefinition(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, first, decoded)
+
+	_, err = decodeRoleDefinition("stream-app")
+	assert.Error(t, err)
+}
+
+func TestRoleDefinitionBind(t *testing.T) {
+	acls, err := streamApp.bind("User:orders", "orders.", "orders-app")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"Cluster|Literal|User:orders|*|IdempotentWrite|Allow|kafka-cluster",
+		"Group|Literal|User:orders|*|Read|Allow|orders-app",
+		"Topic|Prefixed|User:orders|*|All|Allow|orders.",
+	}, aclIDs(acls))
+
+	_, err = streamApp.bind("User:orders", "orders.", "")
+	assert.EqualError(t, err, `Role stream-app uses {group} in "{group}", the binding must set it`)
+}
+
+func TestRoleBindingID(t *testing.T) {
+	encoded, err := streamApp.encode()
+	assert.NoError(t, err)
+
+	id, err := roleBindingID(aclBlock{"role": encoded, "principal": "User:orders"})
+	assert.NoError(t, err)
+	assert.Equal(t, "stream-app|User:orders", id)
+}
+
+func TestEnsureAclsUnclaimed(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	// lists a single ACL of User:test on every resource described
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"DescribeAclsRequest": sarama.NewMockListAclsResponse(t),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	admin := newAdminScheduler(1).broker(broker)
+
+	acl := helper.Acl{
+		ResourceType:   sarama.AclResourceTopic,
+		ResourceName:   "orders",
+		PatternType:    sarama.AclPatternLiteral,
+		Principal:      "User:test",
+		Host:           "*",
+		Operation:      sarama.AclOperationAny,
+		PermissionType: sarama.AclPermissionAllow,
+	}
+	other := acl
+	other.Principal = "User:orders"
+
+	assert.NoError(t, ensureAclsUnclaimed(admin, "role binding", []helper.Acl{other}, nil))
+	// owned by the binding already
+	assert.NoError(t, ensureAclsUnclaimed(admin, "role binding", []helper.Acl{acl, other}, []helper.Acl{acl}))
+
+	err := ensureAclsUnclaimed(admin, "role binding", []helper.Acl{acl, other}, nil)
+	assert.EqualError(t, err, "ACLs "+aclID(acl)+" already exist, a role binding cannot share ACLs with other resources since it deletes them on destroy")
+}
@@ -0,0 +1,343 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Pieces of a service account, in the order they are created
+const (
+	serviceAccountAcls       = "acls"
+	serviceAccountQuota      = "quota"
+	serviceAccountCredential = "credential"
+)
+
+// Values of the status attribute of kafka_service_account besides errors
+const (
+	serviceAccountStatusOk      = "ok"
+	serviceAccountStatusPending = "pending"
+	serviceAccountStatusMissing = "missing"
+)
+
+// resourceKafkaServiceAccount owns the SCRAM credential, the ACLs and the
+// quota of one user. The credential is created last and deleted first, so
+// the user never authenticates without its ACLs and quota in place.
+func resourceKafkaServiceAccount() *schema.Resource {
+	account := &schema.Resource{
+		Create:        resourceKafkaServiceAccountCreate,
+		Read:          resourceKafkaServiceAccountRead,
+		Update:        resourceKafkaServiceAccountUpdate,
+		Delete:        resourceKafkaServiceAccountDelete,
+		CustomizeDiff: resourceKafkaServiceAccountCustomizeDiff,
+		Schema: map[string]*schema.Schema{
+			"principal": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Principal of the user, User:<username>",
+			},
+			"acl": &schema.Schema{
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "ACLs granted to the user",
+				Elem:        aclTemplateResource(),
+			},
+			"acls": ownedAclsSchema("IDs of the ACLs generated from the acl blocks"),
+			"status": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Status of the credential, the ACLs and the quota: ok, pending, missing or the last error",
+			},
+		},
+	}
+
+	for key, value := range scramCredentialSchema() {
+		account.Schema[key] = value
+	}
+
+	quota := quotaSchema()
+	for _, key := range clientQuotaKeys {
+		account.Schema[key] = quota[key]
+	}
+
+	return account
+}
+
+func serviceAccountPrincipal(username string) string {
+	return "User:" + username
+}
+
+func serviceAccountQuotaEntity(username string) []sarama.QuotaEntityComponent {
+	return []sarama.QuotaEntityComponent{quotaEntityComponent(sarama.QuotaEntityUser, username)}
+}
+
+// serviceAccountAclsOf expands the acl blocks for the user
+func serviceAccountAclsOf(username string, blocks []interface{}) ([]helper.Acl, error) {
+	generated := make(map[helper.Acl]bool)
+	for _, item := range blocks {
+		block := item.(map[string]interface{})
+		acl, err := newAcl(
+			block["resource_type"].(string),
+			block["resource_name"].(string),
+			block["resource_pattern_type"].(string),
+			serviceAccountPrincipal(username),
+			block["host"].(string),
+			block["operation"].(string),
+			block["permission_type"].(string),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		generated[acl] = true
+	}
+
+	acls := make([]helper.Acl, 0, len(generated))
+	for acl := range generated {
+		acls = append(acls, acl)
+	}
+	sortAcls(acls)
+
+	return acls, nil
+}
+
+// serviceAccountStatus records the outcome of each piece, pieces not reached
+// because an earlier one failed stay pending
+type serviceAccountStatus map[string]interface{}
+
+func newServiceAccountStatus(current interface{}) serviceAccountStatus {
+	status := serviceAccountStatus{}
+	for key, value := range current.(map[string]interface{}) {
+		status[key] = value
+	}
+
+	return status
+}
+
+// record stores the outcome of a piece in the status attribute
+func (s serviceAccountStatus) record(d *schema.ResourceData, piece string, err error) error {
+	if err != nil {
+		s[piece] = err.Error()
+		err = fmt.Errorf("Error applying the %s of service account %s: %s", piece, d.Id(), err)
+	} else {
+		s[piece] = serviceAccountStatusOk
+	}
+
+	d.Set("status", map[string]interface{}(s))
+	return err
+}
+
+func (s serviceAccountStatus) pending(pieces ...string) {
+	for _, piece := range pieces {
+		s[piece] = serviceAccountStatusPending
+	}
+}
+
+func resourceKafkaServiceAccountCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	if err := customizeDiffScramPassword(d); err != nil {
+		return err
+	}
+
+	// a credential removed out of band is upserted again
+	status := d.Get("status").(map[string]interface{})
+	if status[serviceAccountCredential] == serviceAccountStatusMissing {
+		if err := d.SetNewComputed("status"); err != nil {
+			return err
+		}
+	}
+
+	if !d.NewValueKnown("username") || !d.NewValueKnown("acl") {
+		return d.SetNewComputed("acls")
+	}
+
+	desired, err := serviceAccountAclsOf(d.Get("username").(string), d.Get("acl").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	owned, err := parseAclIDs(d.Get("acls").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	err = ensureAclsUnclaimed(m.(*providerMeta).broker, "service account", desired, owned)
+	if err != nil {
+		return err
+	}
+
+	return customizeDiffOwnedAcls(d, desired)
+}
+
+func resourceKafkaServiceAccountCreate(d *schema.ResourceData, m interface{}) error {
+	broker := m.(*providerMeta).broker
+	username := d.Get("username").(string)
+
+	// kept on failure so that destroy removes what was created
+	d.SetId(username)
+
+	status := serviceAccountStatus{}
+	status.pending(serviceAccountAcls, serviceAccountQuota, serviceAccountCredential)
+
+	// the state is saved even when an earlier piece fails
+	upserted := false
+	defer func() { keepScramPassword(d, upserted) }()
+
+	if err := status.record(d, serviceAccountAcls, applyServiceAccountAcls(d, broker)); err != nil {
+		return err
+	}
+
+	if err := status.record(d, serviceAccountQuota, applyServiceAccountQuota(d, broker)); err != nil {
+		return err
+	}
+
+	err := upsertScramCredential(d, broker)
+	upserted = err == nil
+	if err := status.record(d, serviceAccountCredential, err); err != nil {
+		return err
+	}
+
+	return resourceKafkaServiceAccountRead(d, m)
+}
+
+func resourceKafkaServiceAccountRead(d *schema.ResourceData, m interface{}) error {
+	broker := m.(*providerMeta).broker
+	username := d.Id()
+	status := newServiceAccountStatus(d.Get("status"))
+
+	acls, err := readOwnedAcls(broker, d.Get("acls").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	values, err := describeQuota(broker, serviceAccountQuotaEntity(username))
+	if err != nil {
+		return err
+	}
+
+	iterations, found, err := describeScramCredential(broker, username, d.Get("mechanism").(string))
+	if err != nil {
+		return err
+	}
+
+	if found {
+		status[serviceAccountCredential] = serviceAccountStatusOk
+		d.Set("iterations", iterations)
+	} else {
+		if status[serviceAccountCredential] == serviceAccountStatusOk {
+			log.Printf("[WARN] Kafka: SCRAM credential of service account %s no longer exists", username)
+			status[serviceAccountCredential] = serviceAccountStatusMissing
+		}
+
+		// no password is set on the cluster, so write_only_password is part
+		// of the next diff and the update can upsert the credential with it
+		d.Set("write_only_password", "")
+	}
+
+	d.Set("username", username)
+	d.Set("principal", serviceAccountPrincipal(username))
+	d.Set("acls", acls)
+	quota := flattenQuotaValues(values)
+	for _, key := range clientQuotaKeys {
+		d.Set(key, quota[key])
+	}
+	d.Set("status", map[string]interface{}(status))
+
+	return nil
+}
+
+func resourceKafkaServiceAccountUpdate(d *schema.ResourceData, m interface{}) error {
+	broker := m.(*providerMeta).broker
+
+	// the planned status is unknown when the credential went missing
+	oldStatus, _ := d.GetChange("status")
+	status := newServiceAccountStatus(oldStatus)
+
+	upserted := false
+	defer func() { keepScramPassword(d, upserted) }()
+
+	if d.HasChange("acl") || d.HasChange("acls") {
+		if err := status.record(d, serviceAccountAcls, applyServiceAccountAcls(d, broker)); err != nil {
+			return err
+		}
+	}
+
+	quotaChanged := false
+	for _, key := range clientQuotaKeys {
+		quotaChanged = quotaChanged || d.HasChange(key)
+	}
+	if quotaChanged {
+		if err := status.record(d, serviceAccountQuota, applyServiceAccountQuota(d, broker)); err != nil {
+			return err
+		}
+	}
+
+	missing := status[serviceAccountCredential] != serviceAccountStatusOk
+	if missing || d.HasChange("password") || d.HasChange("write_only_password") {
+		err := upsertScramCredential(d, broker)
+		upserted = err == nil
+		if err := status.record(d, serviceAccountCredential, err); err != nil {
+			return err
+		}
+	}
+
+	return resourceKafkaServiceAccountRead(d, m)
+}
+
+// resourceKafkaServiceAccountDelete removes the credential first so that the
+// user cannot authenticate while its ACLs and quota go away
+func resourceKafkaServiceAccountDelete(d *schema.ResourceData, m interface{}) error {
+	broker := m.(*providerMeta).broker
+	username := d.Id()
+	status := newServiceAccountStatus(d.Get("status"))
+
+	err := deleteScramCredential(broker, username, d.Get("mechanism").(string))
+	if err := status.record(d, serviceAccountCredential, err); err != nil {
+		return err
+	}
+
+	entity := serviceAccountQuotaEntity(username)
+	err = alterQuota(broker, entity, nil, quotaKeys(entity))
+	if err := status.record(d, serviceAccountQuota, err); err != nil {
+		return err
+	}
+
+	owned, err := parseAclIDs(d.Get("acls").(*schema.Set).List())
+	if err == nil {
+		err = deleteAcls(broker, owned)
+	}
+
+	return status.record(d, serviceAccountAcls, err)
+}
+
+func applyServiceAccountAcls(d *schema.ResourceData, broker *adminBroker) error {
+	desired, err := serviceAccountAclsOf(d.Get("username").(string), d.Get("acl").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	// accounts planned together may grant the same ACLs
+	oldVal, _ := d.GetChange("acls")
+	owned, err := parseAclIDs(oldVal.(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	err = ensureAclsUnclaimed(broker, "service account", desired, owned)
+	if err != nil {
+		return err
+	}
+
+	return applyOwnedAcls(d, broker, desired, func(released []helper.Acl) ([]helper.Acl, error) {
+		return nil, deleteAcls(broker, released)
+	})
+}
+
+func applyServiceAccountQuota(d *schema.ResourceData, broker *adminBroker) error {
+	entity := serviceAccountQuotaEntity(d.Get("username").(string))
+	values, removed := expandQuotaValues(d, entity)
+
+	return alterQuota(broker, entity, values, removed)
+}
@@ -0,0 +1,222 @@
+package kafka
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceAccountSchema(t *testing.T) {
+	account := resourceKafkaServiceAccount().Schema
+
+	assert.True(t, account["username"].ForceNew)
+	assert.True(t, account["password"].Sensitive)
+	assert.NotNil(t, account["write_only_password"].DiffSuppressFunc)
+	assert.Contains(t, account, "producer_byte_rate")
+	assert.Contains(t, account, "request_percentage")
+	assert.NotContains(t, account, "connection_creation_rate")
+	assert.True(t, account["status"].Computed)
+}
+
+func TestServiceAccountAclsOf(t *testing.T) {
+	read := map[string]interface{}{
+		"resource_type":         "Topic",
+		"resource_name":         "orders",
+		"resource_pattern_type": "Prefixed",
+		"host":                  "*",
+		"operation":             "Read",
+		"permission_type":       "Allow",
+	}
+	describe := map[string]interface{}{
+		"resource_type":         "Topic",
+		"resource_name":         "orders",
+		"resource_pattern_type": "Prefixed",
+		"host":                  "*",
+		"operation":             "Describe",
+		"permission_type":       "Allow",
+	}
+
+	acls, err := serviceAccountAclsOf("billing", []interface{}{read, describe, read})
+	assert.Nil(t, err)
+	assert.Len(t, acls, 2)
+	for _, acl := range acls {
+		assert.Equal(t, "User:billing", acl.Principal)
+		assert.Equal(t, "orders", acl.ResourceName)
+	}
+
+	second, err := serviceAccountAclsOf("billing", []interface{}{describe, read})
+	assert.Nil(t, err)
+	assert.Equal(t, acls, second)
+
+	invalid := map[string]interface{}{}
+	for key, value := range read {
+		invalid[key] = value
+	}
+	invalid["operation"] = "Fly"
+	_, err = serviceAccountAclsOf("billing", []interface{}{invalid})
+	assert.NotNil(t, err)
+}
+
+func TestServiceAccountStatus(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceKafkaServiceAccount().Schema, map[string]interface{}{
+		"username": "billing",
+		"password": "secret",
+	})
+	d.SetId("billing")
+
+	status := newServiceAccountStatus(map[string]interface{}{serviceAccountCredential: serviceAccountStatusMissing})
+	status.pending(serviceAccountAcls, serviceAccountQuota)
+	assert.Equal(t, serviceAccountStatusPending, status[serviceAccountAcls])
+	assert.Equal(t, serviceAccountStatusMissing, status[serviceAccountCredential])
+
+	assert.Nil(t, status.record(d, serviceAccountAcls, nil))
+	err := status.record(d, serviceAccountQuota, fmt.Errorf("Policy violation"))
+	assert.EqualError(t, err, "Error applying the quota of service account billing: Policy violation")
+
+	recorded := d.Get("status").(map[string]interface{})
+	assert.Equal(t, serviceAccountStatusOk, recorded[serviceAccountAcls])
+	assert.Equal(t, "Policy violation", recorded[serviceAccountQuota])
+	assert.Equal(t, serviceAccountStatusMissing, recorded[serviceAccountCredential])
+}
+
+func TestServiceAccountMissingCredentialUpdate(t *testing.T) {
+	missing := &sarama.DescribeUserScramCredentialsResponse{
+		Results: []*sarama.DescribeUserScramCredentialsResult{{User: "billing", ErrorCode: errScramResourceNotFound}},
+	}
+	found := &sarama.DescribeUserScramCredentialsResponse{
+		Results: []*sarama.DescribeUserScramCredentialsResult{{
+			User:            "billing",
+			CredentialInfos: []*sarama.UserScramCredentialsResponseInfo{{Mechanism: sarama.SCRAM_MECHANISM_SHA_512, Iterations: scramMinIterations}},
+		}},
+	}
+
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"DescribeClientQuotasRequest":         sarama.NewMockWrapper(&sarama.DescribeClientQuotasResponse{}),
+		"DescribeUserScramCredentialsRequest": sarama.NewMockSequence(missing, found),
+		"AlterUserScramCredentialsRequest": sarama.NewMockWrapper(&sarama.AlterUserScramCredentialsResponse{
+			Results: []*sarama.AlterUserScramCredentialsResult{{User: "billing"}},
+		}),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	meta := &providerMeta{broker: newAdminScheduler(1).broker(broker)}
+
+	account := resourceKafkaServiceAccount()
+	hash := scramPasswordHash("billing", sarama.SASLTypeSCRAMSHA512, "secret")
+	d := account.Data(&terraform.InstanceState{
+		ID: "billing",
+		Attributes: map[string]string{
+			"username":            "billing",
+			"mechanism":           sarama.SASLTypeSCRAMSHA512,
+			"iterations":          "4096",
+			"write_only_password": hash,
+			"status.%":            "1",
+			"status.credential":   serviceAccountStatusOk,
+		},
+	})
+
+	// the credential was removed out of band
+	assert.Nil(t, resourceKafkaServiceAccountRead(d, meta))
+	assert.Equal(t, "", d.Get("write_only_password"))
+	assert.Equal(t, serviceAccountStatusMissing, d.Get("status").(map[string]interface{})[serviceAccountCredential])
+
+	// so the next plan carries the configured password to the update
+	state, err := account.Apply(d.State(), &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"write_only_password": {Old: "", New: "secret"},
+			"status.%":            {NewComputed: true},
+		},
+	}, meta)
+	assert.Nil(t, err)
+	assert.Equal(t, hash, state.Attributes["write_only_password"])
+	assert.Equal(t, serviceAccountStatusOk, state.Attributes["status.credential"])
+
+	upserts := 0
+	for _, exchange := range mock.History() {
+		if _, ok := exchange.Request.(*sarama.AlterUserScramCredentialsRequest); ok {
+			upserts++
+		}
+	}
+	assert.Equal(t, 1, upserts)
+}
+
+func TestServiceAccountUpdateKeepsPassword(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"AlterClientQuotasRequest": sarama.NewMockWrapper(&sarama.AlterClientQuotasResponse{
+			Entries: []sarama.AlterClientQuotasEntryResponse{{ErrorCode: sarama.ErrPolicyViolation}},
+		}),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	meta := &providerMeta{broker: newAdminScheduler(1).broker(broker)}
+
+	state := &terraform.InstanceState{
+		ID: "billing",
+		Attributes: map[string]string{
+			"username":          "billing",
+			"mechanism":         sarama.SASLTypeSCRAMSHA512,
+			"iterations":        "4096",
+			"password":          "secret",
+			"status.%":          "1",
+			"status.credential": serviceAccountStatusOk,
+		},
+	}
+
+	// the quota fails before the credential is upserted
+	state, err := resourceKafkaServiceAccount().Apply(state, &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"password":           {Old: "secret", New: "secret2"},
+			"producer_byte_rate": {Old: "-1", New: "1024"},
+		},
+	}, meta)
+	assert.Error(t, err)
+	assert.Equal(t, "secret", state.Attributes["password"])
+	assert.Contains(t, state.Attributes["status.quota"], "Error altering quota")
+}
+
+func TestApplyServiceAccountAclsClaimed(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	// lists a single ACL of User:test on every resource described
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"DescribeAclsRequest": sarama.NewMockListAclsResponse(t),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	d := schema.TestResourceDataRaw(t, resourceKafkaServiceAccount().Schema, map[string]interface{}{
+		"username": "test",
+		"acl": []interface{}{map[string]interface{}{
+			"resource_type":         "Topic",
+			"resource_name":         "orders",
+			"resource_pattern_type": "Literal",
+			"host":                  "*",
+			"operation":             "Any",
+			"permission_type":       "Allow",
+		}},
+	})
+
+	err := applyServiceAccountAcls(d, newAdminScheduler(1).broker(broker))
+	assert.EqualError(t, err, "ACLs Topic|Literal|User:test|*|Any|Allow|orders already exist, a service account cannot share ACLs with other resources since it deletes them on destroy")
+	assert.Zero(t, requestCounts(mock)["*sarama.CreateAclsRequest"])
+}
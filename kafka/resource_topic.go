@@ -4,8 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
-	"github.com/Shopify/sarama"
+	"github.com/IBM/sarama"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
 )
@@ -21,16 +22,23 @@ func resourceKafkaTopic() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
-		Schema: kafkaSchema(),
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(2 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(2 * time.Minute),
+		},
+		CustomizeDiff: resourceKafkaTopicCustomizeDiff,
+		Schema:        kafkaSchema(),
 	}
 }
 
 func kafkaSchema() map[string]*schema.Schema {
 	return map[string]*schema.Schema{
 		"name": &schema.Schema{
-			Type:        schema.TypeString,
-			Required:    true,
-			Description: "Name of topic",
+			Type:         schema.TypeString,
+			Required:     true,
+			Description:  "Name of topic",
+			ValidateFunc: validateTopicName,
 		},
 		"partitions": &schema.Schema{
 			Type:        schema.TypeInt,
@@ -47,93 +55,266 @@ func kafkaSchema() map[string]*schema.Schema {
 			Optional: true,
 			Computed: true,
 		},
+		"replica_assignment": &schema.Schema{
+			Type:        schema.TypeList,
+			Optional:    true,
+			Computed:    true,
+			Description: "Replicas of every partition, the first one being the preferred leader",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"partition": &schema.Schema{
+						Type:     schema.TypeInt,
+						Required: true,
+					},
+					"replicas": &schema.Schema{
+						Type:     schema.TypeList,
+						Required: true,
+						Elem:     &schema.Schema{Type: schema.TypeInt},
+					},
+				},
+			},
+		},
+		"allow_recreate_on_partition_decrease": &schema.Schema{
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Replace the Topic, losing its records, when the number of partitions is reduced. force_destroy must be applied beforehand",
+		},
+		"min_replication_factor": &schema.Schema{
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Lowest replica count among the partitions",
+		},
+		"max_replication_factor": &schema.Schema{
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Highest replica count among the partitions",
+		},
+		"partition_status": partitionStatusSchema(),
+		"uneven_partitions": &schema.Schema{
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Number of partitions whose replica count differs from replication_factor",
+		},
+		"under_replicated_partitions": &schema.Schema{
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Number of partitions with replicas out of sync",
+		},
+		"offline_partitions": &schema.Schema{
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Number of partitions without a leader",
+		},
+		"topic_id": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "ID of the topic, empty on clusters before 2.8",
+		},
+		"migrate_data": &schema.Schema{
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Copy the records into a new topic on rename instead of replacing the Topic",
+		},
+		"migrated_from": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Old name of a migrated Topic which could not be deleted, the deletion is retried on the next apply",
+		},
+		"deletion_mode": &schema.Schema{
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      deletionModeFail,
+			Description:  "Behaviour on clusters with topic deletion disabled: fail, abandon or retire",
+			ValidateFunc: validateDeletionMode,
+		},
+		"force_destroy": &schema.Schema{
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Delete this Topic even if it holds records or has consumers",
+		},
+		"deletion_protection": &schema.Schema{
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Refuse to destroy or replace this Topic, defaults to the provider setting",
+		},
+		"producers": producersSchema(),
+		"consumers": consumersSchema(),
+		"acls":      ownedAclsSchema("IDs of the ACLs generated from producers and consumers"),
+	}
+}
+
+func resourceKafkaTopicCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	meta := m.(*providerMeta)
+
+	if err := customizeDiffTopicName(d, meta); err != nil {
+		return err
+	}
+
+	if err := customizeDiffTopicRename(d); err != nil {
+		return err
+	}
+
+	if err := customizeDiffTopicID(d, meta); err != nil {
+		return err
+	}
+
+	if err := customizeDiffMigratedTopic(d); err != nil {
+		return err
+	}
+
+	if err := customizeDiffPartitions(d); err != nil {
+		return err
+	}
+
+	if err := customizeDiffReplicaAssignment(d); err != nil {
+		return err
+	}
+
+	if err := customizeDiffTopicAcls(d, meta); err != nil {
+		return err
 	}
+
+	// must run last, once every replacement has been planned
+	return customizeDiffDeletionProtection(d, meta)
 }
 
 func resourceKafkaTopicCreate(d *schema.ResourceData, m interface{}) error {
-	broker := m.(*sarama.Broker)
-	defer broker.Close()
+	meta := m.(*providerMeta)
 
 	// Get basic topic properties from input
 	topic, err := r.CreateResourceParams(d)
 
+	timeout := d.Timeout(schema.TimeoutCreate)
+
+	err = createTopic(meta.broker, topic, timeout)
+	meta.topics.invalidate(topic.Name)
+	if err != nil {
+		return err
+	}
+
+	// The topic exists from here on, a timeout while it propagates must not
+	// leave it out of the state
+	d.SetId(topic.Name)
+
+	err = waitForTopicReady(meta.broker, topic.Name, timeout)
+	if err != nil {
+		log.Printf("Error waiting for kafka Topic :: %s", err.Error())
+		return err
+	}
+
+	err = applyTopicAcls(d, meta.broker)
+	if err != nil {
+		return err
+	}
+
+	return resourceKafkaTopicRead(d, m)
+}
+
+// createTopic sends the CreateTopics request. Metadata propagation is
+// asynchronous, callers record the topic before waitForTopicReady.
+func createTopic(broker *adminBroker, topic helper.Topic, timeout time.Duration) error {
 	// Prepare CreateTopicRequest
 	topicRequest := r.CreateKafkaTopicRequest(
 		topic.Name,
 		topic.Partitions,
 		topic.ReplicationFactor,
 		topic.ConfigEntries,
+		topic.ReplicaAssignment,
 	)
 
-	// Create a kafka topic using broker
-	response, err := broker.CreateTopics(topicRequest)
-	if err != nil {
-		log.Printf("Error creating kafka Topic :: %s", err.Error())
-		return err
-	}
-	// check and send error if any
-	if response.TopicErrors[topic.Name].Err != sarama.ErrNoError {
-		return fmt.Errorf("topic error: %v", response.TopicErrors[topic.Name].Err)
-	}
-	d.SetId(topic.Name)
-	return resourceKafkaTopicRead(d, m)
+	// Create a kafka topic using broker, waiting while a previous
+	// deletion of the same topic is still in progress
+	return waitForTopic(topic.Name, timeout, func() (bool, error) {
+		response, err := broker.CreateTopics(topicRequest)
+		if err != nil {
+			log.Printf("Error creating kafka Topic :: %s", err.Error())
+			return false, err
+		}
+
+		topicErr := response.TopicErrors[topic.Name]
+		if topicPendingDeletion(topicErr) {
+			log.Printf("[DEBUG] Kafka: topic %s is still marked for deletion", topic.Name)
+			return false, nil
+		}
+
+		// check and send error if any
+		if topicErr.Err != sarama.ErrNoError {
+			return false, fmt.Errorf("topic error: %v", topicErr.Err)
+		}
+
+		return true, nil
+	})
 }
 
 func resourceKafkaTopicRead(d *schema.ResourceData, m interface{}) error {
-	broker := m.(*sarama.Broker)
-	defer broker.Close()
+	meta := m.(*providerMeta)
 
 	topic := d.Id()
 
-	response, err := broker.GetMetadata(r.GetKafkaMetadataRequest(topic))
+	// Reads are answered from the topics fetched once for the whole run
+	names := []string{topic}
+	migratedFrom := d.Get("migrated_from").(string)
+	if migratedFrom != "" {
+		names = append(names, migratedFrom)
+	}
+
+	cached, err := meta.topics.lookup(meta.broker, names)
 
 	if err != nil {
 		log.Println(err.Error())
 		return err
 	}
 
-	log.Printf("[DEBUG] Kafka: Topic retrieved for %s: %#v", topic, response)
-
-	if len(response.Topics) != 1 {
+	cachedTopic, ok := cached[topic]
+	if !ok {
 		msg := "The requested topic does not exist"
 		log.Println(msg)
 		return errors.New(msg)
 	}
 
-	var partitions int
-	var replicationFactor int
-
-	for _, topic := range response.Topics {
-		partitions = len(topic.Partitions)
-		for _, partition := range topic.Partitions {
-			replicationFactor = len(partition.Replicas)
-			continue
-		}
-	}
+	log.Printf("[DEBUG] Kafka: Topic retrieved for %s: %#v", topic, cachedTopic.metadata)
 
-	d.Set("name", topic)
-	d.Set("partitions", partitions)
-	d.Set("replication_factor", replicationFactor)
+	partitions := len(cachedTopic.metadata.Partitions)
+	replicaAssignment := make(map[int32][]int32)
 
-	configs, err := broker.DescribeConfigs(r.GetKafkaConfigsRequest(topic, r.ValidConfigNames()))
+	partitionStatus, underReplicated, offline := flattenPartitionStatus(cachedTopic.metadata.Partitions)
+	minReplicationFactor, maxReplicationFactor := replicationFactorRange(cachedTopic.metadata.Partitions)
 
-	if err != nil {
-		log.Println(err.Error())
-		return err
+	for _, partition := range cachedTopic.metadata.Partitions {
+		replicaAssignment[partition.ID] = partition.Replicas
 	}
 
-	if len(configs.Resources) != 1 {
-		msg := "The requested topic configs does not exist"
-		log.Println(msg)
-		return errors.New(msg)
+	replicationFactor := readReplicationFactor(
+		topic,
+		d.Get("replication_factor").(int),
+		minReplicationFactor,
+		maxReplicationFactor,
+	)
+
+	// the old topic of a migration was deleted out of band
+	if _, ok := cached[migratedFrom]; migratedFrom != "" && !ok {
+		d.Set("migrated_from", "")
 	}
 
+	d.Set("name", topic)
+	d.Set("partitions", partitions)
+	d.Set("replication_factor", replicationFactor)
+	d.Set("min_replication_factor", minReplicationFactor)
+	d.Set("max_replication_factor", maxReplicationFactor)
+	d.Set("replica_assignment", flattenReplicaAssignment(replicaAssignment))
+	d.Set("partition_status", partitionStatus)
+	d.Set("uneven_partitions", unevenPartitions(cachedTopic.metadata.Partitions, replicationFactor))
+	d.Set("under_replicated_partitions", underReplicated)
+	d.Set("offline_partitions", offline)
+	d.Set("topic_id", readTopicID(topic, d.Get("topic_id").(string), topicID(cachedTopic.metadata)))
+
 	configEntries := make(map[string]interface{})
 
-	for _, resource := range configs.Resources {
-		for _, config := range resource.Configs {
-			configEntries[config.Name] = config.Value
-		}
+	for name, value := range cachedTopic.configs {
+		configEntries[name] = value
 	}
 
 	log.Printf("[DEBUG] configs: %#v", configEntries)
@@ -142,17 +323,41 @@ func resourceKafkaTopicRead(d *schema.ResourceData, m interface{}) error {
 
 	log.Printf("[DEBUG] resource data: %#v", d.Get("config_entries"))
 
+	// ACLs removed out of band are dropped and planned again
+	acls, err := readOwnedAcls(meta.broker, d.Get("acls").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	d.Set("acls", acls)
+
 	return nil
 }
 
 func resourceKafkaTopicUpdate(d *schema.ResourceData, m interface{}) error {
-	broker := m.(*sarama.Broker)
-	defer broker.Close()
+	meta := m.(*providerMeta)
+	broker := meta.broker
 
 	topic := d.Get("name").(string)
 
+	if err := retryMigratedTopicDeletion(d, meta); err != nil {
+		return err
+	}
+
+	// Renames only reach Update with migrate_data, otherwise they force a new topic
+	if d.HasChange("name") {
+		return resourceKafkaTopicMigrate(d, m)
+	}
+
+	// Each applied step is committed to state on its own, so that a failure
+	// part way leaves the state matching the topic
 	d.Partial(true)
 
+	// These only change the provider behaviour and are applied as is
+	for _, key := range []string{"allow_recreate_on_partition_decrease", "migrate_data", "migrated_from", "deletion_mode", "force_destroy", "deletion_protection"} {
+		d.SetPartial(key)
+	}
+
 	// Replication factor cannot be changed
 	if d.HasChange("replication_factor") {
 		msg := "Replication factor cannot be changed on the fly"
@@ -165,12 +370,25 @@ func resourceKafkaTopicUpdate(d *schema.ResourceData, m interface{}) error {
 		oldVal, newVal := d.GetChange("partitions")
 		// Validate the number of partitions
 		if newVal.(int) < oldVal.(int) {
-			msg := fmt.Sprintf("Number of partitions can not be reduced, please provide a value greater than %s", oldVal.(string))
+			msg := fmt.Sprintf("Number of partitions can not be reduced, please provide a value greater than %d", oldVal.(int))
 			log.Println(msg)
 			return errors.New(msg)
 		}
-		request := r.CreateKafkaPartitionRequest(topic, newVal.(int32))
+		// Place the new partitions as configured, or next to the existing ones
+		assignment, err := newPartitionsAssignment(
+			broker,
+			topic,
+			d.Get("replica_assignment").([]interface{}),
+			newVal.(int),
+			d.Get("replication_factor").(int),
+		)
+		if err != nil {
+			return err
+		}
+
+		request := r.CreateKafkaPartitionRequest(topic, int32(newVal.(int)), assignment)
 		response, err := broker.CreatePartitions(request)
+		meta.topics.invalidate(topic)
 		if err != nil {
 			log.Println(err.Error())
 			return err
@@ -180,12 +398,20 @@ func resourceKafkaTopicUpdate(d *schema.ResourceData, m interface{}) error {
 			log.Println(err.Err.Error())
 			return err.Err
 		}
+
+		d.SetPartial("partitions")
+		d.SetPartial("replica_assignment")
+	} else if d.HasChange("replica_assignment") {
+		msg := "Replica assignment of existing partitions cannot be changed"
+		log.Println(msg)
+		return errors.New(msg)
 	}
 
 	if d.HasChange("config_entries") {
 		_, newVal := d.GetChange("config_entries")
 		request := r.AlterTopicConfigsRequest(topic, newVal.(map[string]interface{}))
 		response, err := broker.AlterConfigs(request)
+		meta.topics.invalidate(topic)
 		if err != nil {
 			log.Println(err.Error())
 			return err
@@ -198,9 +424,20 @@ func resourceKafkaTopicUpdate(d *schema.ResourceData, m interface{}) error {
 				return err
 			}
 		}
+
+		d.SetPartial("config_entries")
 	}
 
-	d.SetId(topic)
+	if d.HasChange("producers") || d.HasChange("consumers") || d.HasChange("acls") {
+		err := applyTopicAcls(d, broker)
+		if err != nil {
+			return err
+		}
+
+		d.SetPartial("producers")
+		d.SetPartial("consumers")
+		d.SetPartial("acls")
+	}
 
 	d.Partial(false)
 
@@ -208,8 +445,7 @@ func resourceKafkaTopicUpdate(d *schema.ResourceData, m interface{}) error {
 }
 
 func resourceKafkaTopicDelete(d *schema.ResourceData, m interface{}) error {
-	broker := m.(*sarama.Broker)
-	defer broker.Close()
+	broker := m.(*providerMeta).broker
 
 	topic := d.Id()
 
@@ -217,6 +453,51 @@ func resourceKafkaTopicDelete(d *schema.ResourceData, m interface{}) error {
 		return errors.New("Provide a topic name")
 	}
 
+	if topicDeletionProtected(d, m.(*providerMeta)) {
+		return fmt.Errorf("Topic %s has deletion_protection enabled, disable it before destroying the topic", topic)
+	}
+
+	if !d.Get("force_destroy").(bool) {
+		if err := ensureTopicUnused(broker, topic); err != nil {
+			return err
+		}
+	}
+
+	deletionMode := d.Get("deletion_mode").(string)
+
+	err := deleteTopic(broker, topic, deletionMode, d.Timeout(schema.TimeoutDelete))
+	m.(*providerMeta).topics.invalidate(topic)
+	if err != nil {
+		return err
+	}
+
+	if err := retryMigratedTopicDeletion(d, m.(*providerMeta)); err != nil {
+		return err
+	}
+
+	owned, err := parseAclIDs(d.Get("acls").(*schema.Set).List())
+	if err != nil {
+		return err
+	}
+
+	// shared group ACLs stay with the other topics owning them
+	_, err = releaseTopicAcls(broker, topic, owned)
+	return err
+}
+
+func deleteTopic(broker *adminBroker, topic string, deletionMode string, timeout time.Duration) error {
+	// Brokers before 2.1 accept the deletion and silently keep the topic
+	// when deletion is disabled, later ones reject DeleteTopics v1 with
+	// INVALID_REQUEST, so check the controller configuration first
+	disabled, err := topicDeletionDisabled(broker, topic)
+	if err != nil {
+		return err
+	}
+
+	if disabled {
+		return handleDeletionDisabled(broker, topic, deletionMode)
+	}
+
 	response, err := broker.DeleteTopics(r.DeleteKafkaTopicRequest(topic))
 
 	if err != nil {
@@ -227,5 +508,13 @@ func resourceKafkaTopicDelete(d *schema.ResourceData, m interface{}) error {
 	if response.TopicErrorCodes[topic] != sarama.ErrNoError {
 		return errors.New(response.TopicErrorCodes[topic].Error())
 	}
+
+	// The topic is only marked for deletion at this point, wait until it is gone
+	err = waitForTopicDeleted(broker, topic, timeout)
+	if err != nil {
+		log.Printf("Error waiting for topic deletion %s", err.Error())
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,675 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// topicBatchSize bounds the number of topics sent in a single request
+const topicBatchSize = 100
+
+func resourceKafkaTopics() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKafkaTopicsCreate,
+		Read:   resourceKafkaTopicsRead,
+		Update: resourceKafkaTopicsUpdate,
+		Delete: resourceKafkaTopicsDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceKafkaTopicsImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+		CustomizeDiff: resourceKafkaTopicsCustomizeDiff,
+		Schema:        kafkaTopicsSchema(),
+	}
+}
+
+func kafkaTopicsSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"topic": &schema.Schema{
+			Type:        schema.TypeSet,
+			Required:    true,
+			Description: "Topics managed together, identified by name",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": &schema.Schema{
+						Type:         schema.TypeString,
+						Required:     true,
+						Description:  "Name of topic",
+						ValidateFunc: validateTopicName,
+					},
+					"partitions": &schema.Schema{
+						Type:        schema.TypeInt,
+						Required:    true,
+						Description: "Number of partitions for this Topic",
+					},
+					"replication_factor": &schema.Schema{
+						Type:        schema.TypeInt,
+						Required:    true,
+						Description: "Replication factor for this Topic",
+					},
+					"config_entries": &schema.Schema{
+						Type:        schema.TypeMap,
+						Optional:    true,
+						Description: "Topic configs, only the configured entries are managed",
+					},
+					"deletion_mode": &schema.Schema{
+						Type:         schema.TypeString,
+						Optional:     true,
+						Default:      deletionModeFail,
+						Description:  "Behaviour on clusters with topic deletion disabled: fail, abandon or retire",
+						ValidateFunc: validateDeletionMode,
+					},
+					"deletion_protection": &schema.Schema{
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Default:     false,
+						Description: "Refuse to delete this topic, the provider deletion_protection applies to every topic",
+					},
+				},
+			},
+		},
+		"force_destroy": &schema.Schema{
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Delete topics even if they hold records or have consumers",
+		},
+	}
+}
+
+// resourceKafkaTopicsCustomizeDiff fails the plan when a topic is declared
+// twice, blocks are hashed on their whole content and would both be kept, or
+// when a protected topic is removed from the set
+func resourceKafkaTopicsCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	meta := m.(*providerMeta)
+
+	declared := make(map[string]bool)
+	for _, item := range d.Get("topic").(*schema.Set).List() {
+		name, _ := item.(map[string]interface{})["name"].(string)
+		if name == "" {
+			continue
+		}
+
+		if declared[name] {
+			return fmt.Errorf("Topic %s is declared more than once", name)
+		}
+		declared[name] = true
+	}
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	// Turning the protection off has to be applied before the removal
+	oldVal, _ := d.GetChange("topic")
+	current := topicsByName(oldVal.(*schema.Set))
+	for _, name := range topicNames(current) {
+		if !declared[name] && topicBlockProtected(current[name], meta) {
+			return fmt.Errorf("Topic %s has deletion_protection enabled and cannot be removed", name)
+		}
+	}
+
+	return nil
+}
+
+// topicBlockProtected resolves the deletion_protection of a topic block,
+// which can only add to the provider setting
+func topicBlockProtected(block map[string]interface{}, meta *providerMeta) bool {
+	protected, _ := block["deletion_protection"].(bool)
+	return protected || meta.deletionProtection
+}
+
+func resourceKafkaTopicsCreate(d *schema.ResourceData, m interface{}) error {
+	// the topics change over time, the ID does not follow them
+	d.SetId(resource.PrefixedUniqueId("kafka-topics-"))
+
+	// Topics created before an error stay in state, the others show up in the next plan
+	err := applyTopics(d, m.(*providerMeta), d.Timeout(schema.TimeoutCreate))
+	if err != nil {
+		return err
+	}
+
+	return resourceKafkaTopicsRead(d, m)
+}
+
+func resourceKafkaTopicsRead(d *schema.ResourceData, m interface{}) error {
+	meta := m.(*providerMeta)
+
+	topics := topicsByName(d.Get("topic").(*schema.Set))
+	names := topicNames(topics)
+
+	cached, err := meta.topics.lookup(meta.broker, names)
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	read := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		t, ok := cached[name]
+		if !ok {
+			log.Printf("[WARN] Kafka: topic %s no longer exists, removing it from state", name)
+			continue
+		}
+
+		read = append(read, readTopicBlock(topics[name], t.metadata, t.configs))
+	}
+
+	d.Set("topic", read)
+
+	return nil
+}
+
+// readTopicBlock refreshes a topic block from the cluster, keeping only the
+// config entries managed by the block
+func readTopicBlock(current map[string]interface{}, metadata *sarama.TopicMetadata, configs map[string]string) map[string]interface{} {
+	min, max := replicationFactorRange(metadata.Partitions)
+
+	currentConfigs, _ := current["config_entries"].(map[string]interface{})
+	configEntries := make(map[string]interface{}, len(currentConfigs))
+	for name := range currentConfigs {
+		if value, ok := configs[name]; ok {
+			configEntries[name] = value
+		}
+	}
+
+	replicationFactor, _ := current["replication_factor"].(int)
+
+	// imported blocks only have a name
+	deletionMode, _ := current["deletion_mode"].(string)
+	if deletionMode == "" {
+		deletionMode = deletionModeFail
+	}
+	deletionProtection, _ := current["deletion_protection"].(bool)
+
+	return map[string]interface{}{
+		"name":                metadata.Name,
+		"partitions":          len(metadata.Partitions),
+		"replication_factor":  readReplicationFactor(metadata.Name, replicationFactor, min, max),
+		"config_entries":      configEntries,
+		"deletion_mode":       deletionMode,
+		"deletion_protection": deletionProtection,
+	}
+}
+
+func resourceKafkaTopicsUpdate(d *schema.ResourceData, m interface{}) error {
+	err := applyTopics(d, m.(*providerMeta), d.Timeout(schema.TimeoutUpdate))
+	if err != nil {
+		return err
+	}
+
+	return resourceKafkaTopicsRead(d, m)
+}
+
+func resourceKafkaTopicsDelete(d *schema.ResourceData, m interface{}) error {
+	meta := m.(*providerMeta)
+	topics := topicsByName(d.Get("topic").(*schema.Set))
+
+	names := topicNames(topics)
+
+	failures := make(map[string]error)
+	deleteTopicsBatch(meta, names, topics, d.Get("force_destroy").(bool), d.Timeout(schema.TimeoutDelete), failures)
+	meta.topics.invalidate(names...)
+
+	if len(failures) > 0 {
+		// only the topics which could not be deleted remain in state
+		remaining := make([]interface{}, 0, len(failures))
+		for name := range failures {
+			remaining = append(remaining, topics[name])
+		}
+		d.Set("topic", remaining)
+	}
+
+	return topicsError(failures)
+}
+
+// resourceKafkaTopicsImport accepts a comma separated list of topic names,
+// the resource gets an ID of its own
+func resourceKafkaTopicsImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	topics := make([]interface{}, 0)
+	for _, name := range strings.Split(d.Id(), ",") {
+		if name == "" {
+			continue
+		}
+
+		topics = append(topics, map[string]interface{}{"name": name})
+	}
+
+	if len(topics) == 0 {
+		return nil, errors.New("Import a comma separated list of topic names")
+	}
+
+	d.Set("topic", topics)
+	d.SetId(resource.PrefixedUniqueId("kafka-topics-"))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// applyTopics creates, alters and deletes topics in batches to go from the
+// topics in state to the configured ones. State is updated topic by topic,
+// so that failed topics keep their previous definition.
+func applyTopics(d *schema.ResourceData, meta *providerMeta, timeout time.Duration) error {
+	oldVal, newVal := d.GetChange("topic")
+	current := topicsByName(oldVal.(*schema.Set))
+	desired := topicsByName(newVal.(*schema.Set))
+
+	applied := make(map[string]map[string]interface{}, len(current))
+	for name, topic := range current {
+		applied[name] = topic
+	}
+	failures := make(map[string]error)
+
+	var toCreate []string
+	var toDelete []string
+	var toAlter []string
+	for _, name := range topicNames(desired) {
+		if _, ok := current[name]; ok {
+			toAlter = append(toAlter, name)
+		} else {
+			toCreate = append(toCreate, name)
+		}
+	}
+	for _, name := range topicNames(current) {
+		if _, ok := desired[name]; !ok {
+			toDelete = append(toDelete, name)
+		}
+	}
+
+	for _, name := range createTopicsBatch(meta.broker, toCreate, desired, timeout, failures) {
+		applied[name] = desired[name]
+	}
+	meta.topics.invalidate(toCreate...)
+
+	for _, name := range alterTopicsBatch(meta.broker, toAlter, current, desired, failures) {
+		applied[name] = desired[name]
+	}
+	meta.topics.invalidate(toAlter...)
+
+	for _, name := range deleteTopicsBatch(meta, toDelete, current, d.Get("force_destroy").(bool), timeout, failures) {
+		delete(applied, name)
+	}
+	meta.topics.invalidate(toDelete...)
+
+	topics := make([]interface{}, 0, len(applied))
+	for _, name := range topicNames(applied) {
+		topics = append(topics, applied[name])
+	}
+	d.Set("topic", topics)
+
+	return topicsError(failures)
+}
+
+// createTopicsBatch creates the topics with batched CreateTopics requests and
+// returns the created ones, failures are recorded per topic
+func createTopicsBatch(broker *adminBroker, names []string, desired map[string]map[string]interface{}, timeout time.Duration, failures map[string]error) []string {
+	var created []string
+
+	for _, batch := range topicBatches(names) {
+		topics := make([]helper.Topic, 0, len(batch))
+		for _, name := range batch {
+			topics = append(topics, expandTopicBlock(desired[name]))
+		}
+
+		response, err := broker.CreateTopics(r.CreateKafkaTopicsRequest(topics))
+		if err != nil {
+			log.Printf("Error creating kafka Topics :: %s", err.Error())
+			recordTopicsFailure(failures, batch, err)
+			continue
+		}
+
+		var ready []string
+		for _, name := range batch {
+			topicErr, ok := response.TopicErrors[name]
+			if !ok {
+				failures[name] = errors.New("no result returned by the broker")
+				continue
+			}
+
+			if topicErr.Err != sarama.ErrNoError {
+				failures[name] = kafkaError(topicErr.Err, topicErr.ErrMsg)
+				continue
+			}
+
+			ready = append(ready, name)
+		}
+
+		if len(ready) == 0 {
+			continue
+		}
+
+		// created topics are kept in state even if they are slow to propagate
+		created = append(created, ready...)
+		if err := waitForTopicsReady(broker, ready, timeout); err != nil {
+			recordTopicsFailure(failures, ready, err)
+		}
+	}
+
+	return created
+}
+
+// alterTopicsBatch adds partitions and alters configs with batched requests
+// and returns the topics whose changes have all been applied. Configs are
+// altered incrementally, overrides which are not managed by a block are left
+// alone.
+func alterTopicsBatch(broker *adminBroker, names []string, current, desired map[string]map[string]interface{}, failures map[string]error) []string {
+	partitions := make(map[string]int32)
+	configs := make(map[string]map[string]*string)
+
+	for _, name := range names {
+		oldTopic, newTopic := current[name], desired[name]
+
+		if oldTopic["replication_factor"] != newTopic["replication_factor"] {
+			failures[name] = errors.New("replication factor cannot be changed on the fly")
+			continue
+		}
+
+		oldPartitions, newPartitions := oldTopic["partitions"].(int), newTopic["partitions"].(int)
+		if newPartitions < oldPartitions {
+			failures[name] = fmt.Errorf("number of partitions can not be reduced below %d", oldPartitions)
+			continue
+		}
+
+		if newPartitions > oldPartitions {
+			partitions[name] = int32(newPartitions)
+		}
+
+		newConfigs, _ := newTopic["config_entries"].(map[string]interface{})
+		oldConfigs, _ := oldTopic["config_entries"].(map[string]interface{})
+		if changes := configChanges(oldConfigs, newConfigs); len(changes) > 0 {
+			configs[name] = changes
+		}
+	}
+
+	partitionNames := make([]string, 0, len(partitions))
+	for name := range partitions {
+		partitionNames = append(partitionNames, name)
+	}
+	sort.Strings(partitionNames)
+
+	for _, batch := range topicBatches(partitionNames) {
+		// new partitions are placed as kafka_topic places them
+		metadata, err := broker.GetMetadata(r.GetKafkaTopicsMetadataRequest(batch))
+		if err != nil {
+			log.Println(err.Error())
+			recordTopicsFailure(failures, batch, err)
+			continue
+		}
+
+		counts := make(map[string]int32, len(batch))
+		assignments := make(map[string][][]int32, len(batch))
+		for _, name := range batch {
+			if topicDeleted(metadata, name) {
+				failures[name] = errors.New("topic no longer exists")
+				continue
+			}
+
+			assignment, err := planTopicPartitions(metadata, name, nil, int(partitions[name]), desired[name]["replication_factor"].(int))
+			if err != nil {
+				failures[name] = err
+				continue
+			}
+
+			counts[name] = partitions[name]
+			assignments[name] = assignment
+		}
+		if len(counts) == 0 {
+			continue
+		}
+
+		response, err := broker.CreatePartitions(r.CreateKafkaPartitionsRequest(counts, assignments))
+		if err != nil {
+			log.Println(err.Error())
+			recordTopicsFailure(failures, remainingTopics(batch, failures), err)
+			continue
+		}
+
+		for _, name := range batch {
+			if _, planned := counts[name]; !planned {
+				continue
+			}
+
+			if topicErr, ok := response.TopicPartitionErrors[name]; ok && topicErr.Err != sarama.ErrNoError {
+				failures[name] = kafkaError(topicErr.Err, topicErr.ErrMsg)
+			}
+		}
+	}
+
+	configNames := make([]string, 0, len(configs))
+	for name := range configs {
+		if _, failed := failures[name]; !failed {
+			configNames = append(configNames, name)
+		}
+	}
+	sort.Strings(configNames)
+
+	for _, batch := range topicBatches(configNames) {
+		entries := make(map[string]map[string]*string, len(batch))
+		for _, name := range batch {
+			entries[name] = configs[name]
+		}
+
+		response, err := broker.IncrementalAlterConfigs(r.IncrementalAlterTopicsConfigsRequest(entries))
+		if err != nil {
+			log.Println(err.Error())
+			recordTopicsFailure(failures, batch, err)
+			continue
+		}
+
+		for _, resource := range response.Resources {
+			if resource.ErrorCode != 0 {
+				failures[resource.Name] = errors.New(resource.ErrorMsg)
+			}
+		}
+	}
+
+	var altered []string
+	for _, name := range names {
+		if _, failed := failures[name]; !failed {
+			altered = append(altered, name)
+		}
+	}
+
+	return altered
+}
+
+// deleteTopicsBatch deletes the topics with batched DeleteTopics requests and
+// returns the deleted ones, failures are recorded per topic. Topics are
+// removed according to their deletion_mode on clusters with topic deletion
+// disabled.
+func deleteTopicsBatch(meta *providerMeta, names []string, topics map[string]map[string]interface{}, forceDestroy bool, timeout time.Duration, failures map[string]error) []string {
+	broker := meta.broker
+
+	var deletable []string
+	for _, name := range names {
+		if topicBlockProtected(topics[name], meta) {
+			failures[name] = errors.New("deletion_protection is enabled")
+			continue
+		}
+
+		deletable = append(deletable, name)
+	}
+
+	if !forceDestroy {
+		deletable = ensureTopicsUnused(broker, deletable, failures)
+	}
+
+	if len(deletable) == 0 {
+		return nil
+	}
+
+	// delete.topic.enable is a broker setting, the controller decides for
+	// every topic
+	disabled, err := topicDeletionDisabled(broker, deletable[0])
+	if err != nil {
+		recordTopicsFailure(failures, deletable, err)
+		return nil
+	}
+
+	if disabled {
+		var removed []string
+		for _, name := range deletable {
+			mode, _ := topics[name]["deletion_mode"].(string)
+			if err := handleDeletionDisabled(broker, name, mode); err != nil {
+				failures[name] = err
+				continue
+			}
+
+			removed = append(removed, name)
+		}
+
+		return removed
+	}
+
+	var deleted []string
+	for _, batch := range topicBatches(deletable) {
+		response, err := broker.DeleteTopics(r.DeleteKafkaTopicsRequest(batch))
+		if err != nil {
+			log.Printf("Error Deleting topics %s", err.Error())
+			recordTopicsFailure(failures, batch, err)
+			continue
+		}
+
+		var pending []string
+		for _, name := range batch {
+			if code, ok := response.TopicErrorCodes[name]; ok && code != sarama.ErrNoError {
+				failures[name] = code
+				continue
+			}
+
+			pending = append(pending, name)
+		}
+
+		if len(pending) == 0 {
+			continue
+		}
+
+		if err := waitForTopicsDeleted(broker, pending, timeout); err != nil {
+			recordTopicsFailure(failures, pending, err)
+			continue
+		}
+
+		deleted = append(deleted, pending...)
+	}
+
+	return deleted
+}
+
+// configChanges returns the config entries to set and, as nil values, the
+// entries to delete to go from the old to the new config_entries
+func configChanges(oldConfigs, newConfigs map[string]interface{}) map[string]*string {
+	changes := make(map[string]*string)
+	for name, value := range newConfigs {
+		if old, ok := oldConfigs[name]; !ok || old != value {
+			entry := value.(string)
+			changes[name] = &entry
+		}
+	}
+
+	for name := range oldConfigs {
+		if _, ok := newConfigs[name]; !ok {
+			changes[name] = nil
+		}
+	}
+
+	return changes
+}
+
+// expandTopicBlock converts a topic block into the helper representation
+func expandTopicBlock(block map[string]interface{}) helper.Topic {
+	configEntries := make(map[string]*string)
+	configs, _ := block["config_entries"].(map[string]interface{})
+	for config, entry := range configs {
+		entryValue := entry.(string)
+		configEntries[config] = &entryValue
+	}
+
+	return helper.Topic{
+		Name:              block["name"].(string),
+		Partitions:        block["partitions"].(int),
+		ReplicationFactor: block["replication_factor"].(int),
+		ConfigEntries:     configEntries,
+	}
+}
+
+// topicsByName indexes the topic blocks of a set by name
+func topicsByName(set *schema.Set) map[string]map[string]interface{} {
+	topics := make(map[string]map[string]interface{}, set.Len())
+	for _, item := range set.List() {
+		block := item.(map[string]interface{})
+		topics[block["name"].(string)] = block
+	}
+
+	return topics
+}
+
+func topicNames(topics map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(topics))
+	for name := range topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// topicBatches splits topic names into groups of at most topicBatchSize
+func topicBatches(names []string) [][]string {
+	var batches [][]string
+	for start := 0; start < len(names); start += topicBatchSize {
+		end := start + topicBatchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		batches = append(batches, names[start:end])
+	}
+
+	return batches
+}
+
+// kafkaError appends the broker message to the error when there is one
+func kafkaError(err sarama.KError, msg *string) error {
+	if msg != nil && *msg != "" {
+		return fmt.Errorf("%s: %s", err.Error(), *msg)
+	}
+
+	return err
+}
+
+func recordTopicsFailure(failures map[string]error, names []string, err error) {
+	for _, name := range names {
+		failures[name] = err
+	}
+}
+
+// topicsError reports every failed topic in a single error
+func topicsError(failures map[string]error) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(failures))
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("  %s: %s", name, failures[name]))
+	}
+
+	return fmt.Errorf("%d topic(s) failed:\n%s", len(names), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicsByName(t *testing.T) {
+	topic := resourceKafkaTopics().Schema["topic"].Elem.(*schema.Resource)
+	set := schema.NewSet(schema.HashResource(topic), []interface{}{
+		map[string]interface{}{"name": "b"},
+		map[string]interface{}{"name": "a"},
+	})
+
+	topics := topicsByName(set)
+	assert.Len(t, topics, 2)
+	assert.Equal(t, []string{"a", "b"}, topicNames(topics))
+}
+
+func TestTopicBatches(t *testing.T) {
+	assert.Nil(t, topicBatches(nil))
+
+	names := make([]string, topicBatchSize+1)
+	for i := range names {
+		names[i] = fmt.Sprintf("topic-%d", i)
+	}
+
+	batches := topicBatches(names)
+	assert.Len(t, batches, 2)
+	assert.Len(t, batches[0], topicBatchSize)
+	assert.Equal(t, []string{names[topicBatchSize]}, batches[1])
+}
+
+func TestTopicsError(t *testing.T) {
+	assert.Nil(t, topicsError(map[string]error{}))
+
+	err := topicsError(map[string]error{
+		"b": errors.New("second"),
+		"a": errors.New("first"),
+	})
+	assert.EqualError(t, err, "2 topic(s) failed:\n  a: first\n  b: second")
+}
+
+func TestKafkaError(t *testing.T) {
+	msg := "already there"
+	assert.EqualError(t, kafkaError(sarama.ErrTopicAlreadyExists, &msg), sarama.ErrTopicAlreadyExists.Error()+": already there")
+	assert.Equal(t, sarama.ErrTopicAlreadyExists, kafkaError(sarama.ErrTopicAlreadyExists, nil))
+}
+
+func TestExpandTopicBlock(t *testing.T) {
+	topic := expandTopicBlock(map[string]interface{}{
+		"name":               "mytopic",
+		"partitions":         3,
+		"replication_factor": 2,
+		"config_entries":     map[string]interface{}{"retention.ms": "1000"},
+	})
+
+	assert.Equal(t, "mytopic", topic.Name)
+	assert.Equal(t, 3, topic.Partitions)
+	assert.Equal(t, 2, topic.ReplicationFactor)
+	assert.Equal(t, "1000", *topic.ConfigEntries["retention.ms"])
+}
+
+func TestReadTopicBlock(t *testing.T) {
+	current := map[string]interface{}{
+		"name":               "mytopic",
+		"partitions":         1,
+		"replication_factor": 2,
+		"config_entries":     map[string]interface{}{"retention.ms": "1000"},
+	}
+	metadata := &sarama.TopicMetadata{
+		Name: "mytopic",
+		Partitions: []*sarama.PartitionMetadata{
+			{ID: 0, Replicas: []int32{1, 2}},
+			{ID: 1, Replicas: []int32{2, 3}},
+		},
+	}
+
+	block := readTopicBlock(current, metadata, map[string]string{"retention.ms": "2000", "cleanup.policy": "delete"})
+	assert.Equal(t, "mytopic", block["name"])
+	assert.Equal(t, 2, block["partitions"])
+	assert.Equal(t, 2, block["replication_factor"])
+	assert.Equal(t, map[string]interface{}{"retention.ms": "2000"}, block["config_entries"])
+	// imported blocks get the defaults
+	assert.Equal(t, deletionModeFail, block["deletion_mode"])
+	assert.Equal(t, false, block["deletion_protection"])
+}
+
+func TestConfigChanges(t *testing.T) {
+	assert.Empty(t, configChanges(
+		map[string]interface{}{"retention.ms": "1000"},
+		map[string]interface{}{"retention.ms": "1000"},
+	))
+
+	changes := configChanges(
+		map[string]interface{}{"retention.ms": "1000", "cleanup.policy": "compact"},
+		map[string]interface{}{"retention.ms": "2000", "segment.ms": "3000"},
+	)
+	assert.Len(t, changes, 3)
+	assert.Equal(t, "2000", *changes["retention.ms"])
+	assert.Equal(t, "3000", *changes["segment.ms"])
+	assert.Nil(t, changes["cleanup.policy"])
+}
+
+func TestTopicBlockProtected(t *testing.T) {
+	assert.False(t, topicBlockProtected(map[string]interface{}{"name": "a"}, &providerMeta{}))
+	assert.True(t, topicBlockProtected(map[string]interface{}{"deletion_protection": true}, &providerMeta{}))
+	assert.True(t, topicBlockProtected(map[string]interface{}{"deletion_protection": false}, &providerMeta{deletionProtection: true}))
+}
+
+func TestAlterTopicsBatchAssignsNewPartitions(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+
+	metadata := &sarama.MetadataResponse{Version: 5}
+	metadata.AddBroker(mock.Addr(), mock.BrokerID())
+	metadata.AddBroker("localhost:9093", 2)
+	metadata.AddBroker("localhost:9094", 3)
+	metadata.AddTopicPartition("orders", 0, 1, []int32{1, 2}, []int32{1, 2}, nil, sarama.ErrNoError)
+	metadata.AddTopicPartition("orders", 1, 2, []int32{2, 1}, []int32{2, 1}, nil, sarama.ErrNoError)
+	metadata.AddTopic("missing", sarama.ErrUnknownTopicOrPartition)
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":         sarama.NewMockWrapper(metadata),
+		"CreatePartitionsRequest": sarama.NewMockCreatePartitionsResponse(t),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	current := map[string]map[string]interface{}{
+		"orders":  {"name": "orders", "partitions": 2, "replication_factor": 2},
+		"missing": {"name": "missing", "partitions": 1, "replication_factor": 2},
+	}
+	desired := map[string]map[string]interface{}{
+		"orders":  {"name": "orders", "partitions": 3, "replication_factor": 2},
+		"missing": {"name": "missing", "partitions": 2, "replication_factor": 2},
+	}
+
+	failures := make(map[string]error)
+	altered := alterTopicsBatch(newAdminScheduler(1).broker(broker), []string{"missing", "orders"}, current, desired, failures)
+	assert.Equal(t, []string{"orders"}, altered)
+	assert.EqualError(t, failures["missing"], "topic no longer exists")
+
+	var request *sarama.CreatePartitionsRequest
+	for _, exchange := range mock.History() {
+		if created, ok := exchange.Request.(*sarama.CreatePartitionsRequest); ok {
+			request = created
+		}
+	}
+	if assert.NotNil(t, request) {
+		assert.Len(t, request.TopicPartitions, 1)
+		// broker 3 holds no replica of orders yet
+		assert.Equal(t, int32(3), request.TopicPartitions["orders"].Count)
+		assert.Equal(t, [][]int32{{3, 1}}, request.TopicPartitions["orders"].Assignment)
+	}
+}
@@ -0,0 +1,404 @@
+package kafka
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Bounds Kafka puts on the iterations of a SCRAM credential
+const (
+	scramMinIterations = 4096
+	scramMaxIterations = 16384
+)
+
+// scramSaltSize is the size of the random salt of each credential
+const scramSaltSize = 32
+
+// errScramResourceNotFound is returned for users without any credential,
+// sarama has no constant for it yet
+const errScramResourceNotFound sarama.KError = 91
+
+// scramPasswordHashPrefix marks the hashes of write_only_password
+const scramPasswordHashPrefix = "hi-sha512:"
+
+// scramPasswordHashSalt prefixes the salt of the hash of write_only_password
+// kept in the state, the rest of the salt is the ID of the credential. It must
+// never change or every credential would be rotated.
+const scramPasswordHashSalt = "terraform-provider-kafka/write_only_password/"
+
+// Mechanisms accepted by kafka_user_scram_credential
+var scramMechanisms = []string{sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512}
+
+func resourceKafkaUserScramCredential() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceKafkaUserScramCredentialCreate,
+		Read:   resourceKafkaUserScramCredentialRead,
+		Update: resourceKafkaUserScramCredentialUpdate,
+		Delete: resourceKafkaUserScramCredentialDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceKafkaUserScramCredentialImport,
+		},
+		CustomizeDiff: resourceKafkaUserScramCredentialCustomizeDiff,
+		Schema:        scramCredentialSchema(),
+	}
+}
+
+// scramCredentialSchema describes a SCRAM credential, shared with
+// kafka_service_account
+func scramCredentialSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"username": &schema.Schema{
+			Type:        schema.TypeString,
+			Required:    true,
+			ForceNew:    true,
+			Description: "Name of the user, without the User: prefix",
+		},
+		"mechanism": &schema.Schema{
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			Default:      sarama.SASLTypeSCRAMSHA512,
+			Description:  "SCRAM mechanism of the credential",
+			ValidateFunc: validateAclValue(scramMechanisms),
+		},
+		"iterations": &schema.Schema{
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ForceNew:     true,
+			Default:      scramMinIterations,
+			Description:  "Iterations of the salted password, changing them replaces the credential",
+			ValidateFunc: validateScramIterations,
+		},
+		"password": &schema.Schema{
+			Type:          schema.TypeString,
+			Optional:      true,
+			Sensitive:     true,
+			Description:   "Password of the user, kept in the state",
+			ConflictsWith: []string{"write_only_password"},
+		},
+		"write_only_password": &schema.Schema{
+			Type:             schema.TypeString,
+			Optional:         true,
+			Sensitive:        true,
+			Description:      "Password of the user, the state only keeps a hash of the salted password",
+			DiffSuppressFunc: suppressScramPasswordHash,
+		},
+	}
+}
+
+func validateScramIterations(v interface{}, k string) (ws []string, errors []error) {
+	iterations := v.(int)
+	if iterations < scramMinIterations || iterations > scramMaxIterations {
+		errors = append(errors, fmt.Errorf("%s must be between %d and %d, got %d", k, scramMinIterations, scramMaxIterations, iterations))
+	}
+	return
+}
+
+// scramPasswordHash is the value of write_only_password kept in the state:
+// the SCRAM salted password (RFC 5802 Hi) with a salt derived from the
+// credential, so that changes are still planned without the same password
+// hashing the same for every user
+func scramPasswordHash(username string, mechanism string, password string) string {
+	if password == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha512.New, []byte(password))
+	mac.Write([]byte(scramPasswordHashSalt + scramCredentialID(username, mechanism)))
+	block := make([]byte, 4)
+	binary.BigEndian.PutUint32(block, 1)
+	mac.Write(block)
+	u := mac.Sum(nil)
+
+	salted := append([]byte{}, u...)
+	for i := 1; i < scramMinIterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range salted {
+			salted[j] ^= u[j]
+		}
+	}
+
+	return scramPasswordHashPrefix + hex.EncodeToString(salted)
+}
+
+// suppressScramPasswordHash compares the configured write_only_password to
+// the hash kept in the state
+func suppressScramPasswordHash(k, old, new string, d *schema.ResourceData) bool {
+	return new != "" && old == scramPasswordHash(d.Get("username").(string), d.Get("mechanism").(string), new)
+}
+
+// keepScramPassword prepares the passwords kept in the state: the hash of the
+// new write_only_password once the credential is upserted, the previous
+// password and hash otherwise so that the rotation is planned again
+func keepScramPassword(d *schema.ResourceData, upserted bool) {
+	if !upserted && d.HasChange("password") {
+		old, _ := d.GetChange("password")
+		d.Set("password", old)
+	}
+
+	if !d.HasChange("write_only_password") {
+		return
+	}
+
+	old, new := d.GetChange("write_only_password")
+	if !upserted {
+		d.Set("write_only_password", old)
+		return
+	}
+
+	d.Set("write_only_password", scramPasswordHash(d.Get("username").(string), d.Get("mechanism").(string), new.(string)))
+}
+
+func scramMechanism(name string) (sarama.ScramMechanismType, error) {
+	switch name {
+	case sarama.SASLTypeSCRAMSHA256:
+		return sarama.SCRAM_MECHANISM_SHA_256, nil
+	case sarama.SASLTypeSCRAMSHA512:
+		return sarama.SCRAM_MECHANISM_SHA_512, nil
+	}
+
+	return sarama.SCRAM_MECHANISM_UNKNOWN, fmt.Errorf("mechanism must be one of %v, got %q", scramMechanisms, name)
+}
+
+// scramCredentialID joins the username and the mechanism
+func scramCredentialID(username string, mechanism string) string {
+	return strings.Join([]string{username, mechanism}, "|")
+}
+
+// parseScramCredentialID reverses scramCredentialID, the username may
+// contain the separator
+func parseScramCredentialID(id string) (string, string, error) {
+	i := strings.LastIndex(id, "|")
+	if i <= 0 {
+		return "", "", fmt.Errorf("SCRAM credential ID %q must be username|mechanism", id)
+	}
+
+	username, mechanism := id[:i], strings.ToUpper(id[i+1:])
+	if _, err := scramMechanism(mechanism); err != nil {
+		return "", "", err
+	}
+
+	return username, mechanism, nil
+}
+
+func resourceKafkaUserScramCredentialCustomizeDiff(d *schema.ResourceDiff, m interface{}) error {
+	return customizeDiffScramPassword(d)
+}
+
+// customizeDiffScramPassword requires one of the password attributes
+func customizeDiffScramPassword(d *schema.ResourceDiff) error {
+	if !d.NewValueKnown("password") || !d.NewValueKnown("write_only_password") {
+		return nil
+	}
+
+	if d.Get("password").(string) == "" && d.Get("write_only_password").(string) == "" {
+		return fmt.Errorf("One of password or write_only_password must be set")
+	}
+
+	return nil
+}
+
+// scramPassword returns the plain password of the resource. During apply
+// write_only_password reads as the configured value, not its hash, as long
+// as it is part of the diff.
+func scramPassword(d *schema.ResourceData) (string, error) {
+	if password := d.Get("password").(string); password != "" {
+		return password, nil
+	}
+
+	password := d.Get("write_only_password").(string)
+	if password == "" || !d.HasChange("write_only_password") || strings.HasPrefix(password, scramPasswordHashPrefix) {
+		return "", fmt.Errorf("The password of %s is needed to change its credential", d.Get("username").(string))
+	}
+
+	return password, nil
+}
+
+func resourceKafkaUserScramCredentialCreate(d *schema.ResourceData, m interface{}) error {
+	username := d.Get("username").(string)
+	mechanism := d.Get("mechanism").(string)
+
+	err := upsertScramCredential(d, m.(*providerMeta).broker)
+	keepScramPassword(d, err == nil)
+	if err != nil {
+		return err
+	}
+
+	d.SetId(scramCredentialID(username, mechanism))
+	return resourceKafkaUserScramCredentialRead(d, m)
+}
+
+func resourceKafkaUserScramCredentialRead(d *schema.ResourceData, m interface{}) error {
+	username, mechanism, err := parseScramCredentialID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	iterations, found, err := describeScramCredential(m.(*providerMeta).broker, username, mechanism)
+	if err != nil {
+		return err
+	}
+
+	// removed out of band, the next plan creates it again
+	if !found {
+		log.Printf("[WARN] Kafka: SCRAM credential %s no longer exists, removing it from state", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("username", username)
+	d.Set("mechanism", mechanism)
+	d.Set("iterations", iterations)
+
+	return nil
+}
+
+// resourceKafkaUserScramCredentialUpdate rotates the credential, only the
+// password can change in place
+func resourceKafkaUserScramCredentialUpdate(d *schema.ResourceData, m interface{}) error {
+	if !d.HasChange("password") && !d.HasChange("write_only_password") {
+		return resourceKafkaUserScramCredentialRead(d, m)
+	}
+
+	// switching between password and write_only_password with the same
+	// value still rotates the salt, which is harmless
+	err := upsertScramCredential(d, m.(*providerMeta).broker)
+	keepScramPassword(d, err == nil)
+	if err != nil {
+		return err
+	}
+
+	return resourceKafkaUserScramCredentialRead(d, m)
+}
+
+func resourceKafkaUserScramCredentialDelete(d *schema.ResourceData, m interface{}) error {
+	username, mechanism, err := parseScramCredentialID(d.Id())
+	if err != nil {
+		return err
+	}
+
+	return deleteScramCredential(m.(*providerMeta).broker, username, mechanism)
+}
+
+// resourceKafkaUserScramCredentialImport accepts username|mechanism, the
+// password is unknown so the next apply rotates it to the configured one
+func resourceKafkaUserScramCredentialImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	username, mechanism, err := parseScramCredentialID(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(scramCredentialID(username, mechanism))
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// describeScramCredential returns the iterations of the credential of a user
+func describeScramCredential(broker *adminBroker, username string, mechanism string) (int, bool, error) {
+	mechanismType, err := scramMechanism(mechanism)
+	if err != nil {
+		return 0, false, err
+	}
+
+	response, err := broker.DescribeUserScramCredentials(r.DescribeKafkaUserScramCredentialsRequest([]string{username}))
+	if err != nil {
+		log.Println(err.Error())
+		return 0, false, err
+	}
+
+	if response.ErrorCode != sarama.ErrNoError {
+		return 0, false, kafkaError(response.ErrorCode, response.ErrorMessage)
+	}
+
+	for _, result := range response.Results {
+		if result.User != username {
+			continue
+		}
+
+		if result.ErrorCode == errScramResourceNotFound {
+			return 0, false, nil
+		}
+
+		if result.ErrorCode != sarama.ErrNoError {
+			return 0, false, kafkaError(result.ErrorCode, result.ErrorMessage)
+		}
+
+		for _, info := range result.CredentialInfos {
+			if info.Mechanism == mechanismType {
+				return int(info.Iterations), true, nil
+			}
+		}
+	}
+
+	return 0, false, nil
+}
+
+// upsertScramCredential salts the password with a new random salt, the
+// password itself is never sent to Kafka
+func upsertScramCredential(d *schema.ResourceData, broker *adminBroker) error {
+	username := d.Get("username").(string)
+
+	mechanism, err := scramMechanism(d.Get("mechanism").(string))
+	if err != nil {
+		return err
+	}
+
+	password, err := scramPassword(d)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, scramSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	request := r.UpsertKafkaUserScramCredentialRequest(username, mechanism, int32(d.Get("iterations").(int)), salt, []byte(password))
+	return alterScramCredentials(broker, request)
+}
+
+func deleteScramCredential(broker *adminBroker, username string, mechanism string) error {
+	mechanismType, err := scramMechanism(mechanism)
+	if err != nil {
+		return err
+	}
+
+	err = alterScramCredentials(broker, r.DeleteKafkaUserScramCredentialRequest(username, mechanismType))
+	if err == errScramResourceNotFound {
+		log.Printf("[DEBUG] Kafka: SCRAM credential %s already deleted", scramCredentialID(username, mechanism))
+		return nil
+	}
+
+	return err
+}
+
+func alterScramCredentials(broker *adminBroker, request *sarama.AlterUserScramCredentialsRequest) error {
+	response, err := broker.AlterUserScramCredentials(request)
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	for _, result := range response.Results {
+		if result.ErrorCode == errScramResourceNotFound {
+			return errScramResourceNotFound
+		}
+
+		if result.ErrorCode != sarama.ErrNoError {
+			return fmt.Errorf("Error altering SCRAM credential of %s: %s", result.User, kafkaError(result.ErrorCode, result.ErrorMessage))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScramCredentialID(t *testing.T) {
+	id := scramCredentialID("billing|svc", sarama.SASLTypeSCRAMSHA512)
+	assert.Equal(t, "billing|svc|SCRAM-SHA-512", id)
+
+	username, mechanism, err := parseScramCredentialID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "billing|svc", username)
+	assert.Equal(t, sarama.SASLTypeSCRAMSHA512, mechanism)
+
+	// mechanisms are case insensitive on import
+	_, mechanism, err = parseScramCredentialID("alice|scram-sha-256")
+	assert.NoError(t, err)
+	assert.Equal(t, sarama.SASLTypeSCRAMSHA256, mechanism)
+
+	_, _, err = parseScramCredentialID("alice")
+	assert.Error(t, err)
+
+	_, _, err = parseScramCredentialID("alice|PLAIN")
+	assert.Error(t, err)
+}
+
+func TestScramMechanism(t *testing.T) {
+	mechanism, err := scramMechanism(sarama.SASLTypeSCRAMSHA256)
+	assert.NoError(t, err)
+	assert.Equal(t, sarama.SCRAM_MECHANISM_SHA_256, mechanism)
+
+	_, err = scramMechanism("scram-sha-256")
+	assert.Error(t, err)
+}
+
+func TestValidateScramIterations(t *testing.T) {
+	_, errs := validateScramIterations(8192, "iterations")
+	assert.Empty(t, errs)
+
+	_, errs = validateScramIterations(1000, "iterations")
+	assert.Len(t, errs, 1)
+
+	_, errs = validateScramIterations(20000, "iterations")
+	assert.Len(t, errs, 1)
+}
+
+func TestScramPasswordHash(t *testing.T) {
+	hash := scramPasswordHash("alice", sarama.SASLTypeSCRAMSHA512, "secret")
+	assert.True(t, strings.HasPrefix(hash, scramPasswordHashPrefix))
+	assert.NotContains(t, hash, "secret")
+	assert.Equal(t, hash, scramPasswordHash("alice", sarama.SASLTypeSCRAMSHA512, "secret"))
+	assert.NotEqual(t, hash, scramPasswordHash("alice", sarama.SASLTypeSCRAMSHA512, "secret2"))
+	assert.Equal(t, "", scramPasswordHash("alice", sarama.SASLTypeSCRAMSHA512, ""))
+
+	// salted per credential
+	assert.NotEqual(t, hash, scramPasswordHash("bob", sarama.SASLTypeSCRAMSHA512, "secret"))
+	assert.NotEqual(t, hash, scramPasswordHash("alice", sarama.SASLTypeSCRAMSHA256, "secret"))
+}
+
+func TestKeepScramPassword(t *testing.T) {
+	raw := map[string]interface{}{
+		"username":            "alice",
+		"write_only_password": "secret",
+	}
+	hash := scramPasswordHash("alice", sarama.SASLTypeSCRAMSHA512, "secret")
+
+	d := schema.TestResourceDataRaw(t, resourceKafkaUserScramCredential().Schema, raw)
+	keepScramPassword(d, true)
+	assert.Equal(t, hash, d.Get("write_only_password"))
+	assert.True(t, suppressScramPasswordHash("write_only_password", hash, "secret", d))
+	assert.False(t, suppressScramPasswordHash("write_only_password", hash, "secret2", d))
+
+	// the previous hash is kept when the credential was not upserted
+	d = schema.TestResourceDataRaw(t, resourceKafkaUserScramCredential().Schema, raw)
+	keepScramPassword(d, false)
+	assert.Equal(t, "", d.Get("write_only_password"))
+}
+
+func TestScramCredentialUpdateKeepsPassword(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"AlterUserScramCredentialsRequest": sarama.NewMockWrapper(&sarama.AlterUserScramCredentialsResponse{
+			Results: []*sarama.AlterUserScramCredentialsResult{{User: "alice", ErrorCode: sarama.ErrPolicyViolation}},
+		}),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	meta := &providerMeta{broker: newAdminScheduler(1).broker(broker)}
+
+	state := &terraform.InstanceState{
+		ID: "alice|SCRAM-SHA-512",
+		Attributes: map[string]string{
+			"username":   "alice",
+			"mechanism":  sarama.SASLTypeSCRAMSHA512,
+			"iterations": "4096",
+			"password":   "secret",
+		},
+	}
+
+	// the failed rotation is planned again
+	state, err := resourceKafkaUserScramCredential().Apply(state, &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"password": {Old: "secret", New: "secret2"},
+		},
+	}, meta)
+	assert.Error(t, err)
+	assert.Equal(t, "secret", state.Attributes["password"])
+}
@@ -0,0 +1,301 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func producersSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeSet,
+		Optional:    true,
+		Description: "Principals allowed to WRITE and DESCRIBE the Topic",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"principals": &schema.Schema{
+					Type:     schema.TypeSet,
+					Required: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+			},
+		},
+	}
+}
+
+func consumersSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeSet,
+		Optional:    true,
+		Description: "Principals allowed to READ the Topic through the given consumer groups",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"principals": &schema.Schema{
+					Type:     schema.TypeSet,
+					Required: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"groups": &schema.Schema{
+					Type:     schema.TypeSet,
+					Required: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+			},
+		},
+	}
+}
+
+// topicAcls generates the ACLs of the producers and consumers of a topic:
+// WRITE and DESCRIBE on the topic for producers, READ on the topic and on
+// their groups for consumers
+func topicAcls(topic string, producers []interface{}, consumers []interface{}) []helper.Acl {
+	generated := make(map[helper.Acl]bool)
+	add := func(resourceType sarama.AclResourceType, name string, principal string, operation sarama.AclOperation) {
+		generated[helper.Acl{
+			ResourceType:   resourceType,
+			ResourceName:   name,
+			PatternType:    sarama.AclPatternLiteral,
+			Principal:      principal,
+			Host:           "*",
+			Operation:      operation,
+			PermissionType: sarama.AclPermissionAllow,
+		}] = true
+	}
+
+	for _, item := range producers {
+		block := item.(map[string]interface{})
+		for _, principal := range block["principals"].(*schema.Set).List() {
+			add(sarama.AclResourceTopic, topic, principal.(string), sarama.AclOperationWrite)
+			add(sarama.AclResourceTopic, topic, principal.(string), sarama.AclOperationDescribe)
+		}
+	}
+
+	for _, item := range consumers {
+		block := item.(map[string]interface{})
+		for _, principal := range block["principals"].(*schema.Set).List() {
+			add(sarama.AclResourceTopic, topic, principal.(string), sarama.AclOperationRead)
+			for _, group := range block["groups"].(*schema.Set).List() {
+				add(sarama.AclResourceGroup, group.(string), principal.(string), sarama.AclOperationRead)
+			}
+		}
+	}
+
+	acls := make([]helper.Acl, 0, len(generated))
+	for acl := range generated {
+		acls = append(acls, acl)
+	}
+	sortAcls(acls)
+
+	return acls
+}
+
+// customizeDiffTopicAcls plans the ACLs generated from producers and
+// consumers, ACLs removed out of band were dropped from acls on refresh and
+// are planned again. Group ACLs which releaseTopicAcls would keep stay owned.
+func customizeDiffTopicAcls(d *schema.ResourceDiff, meta *providerMeta) error {
+	for _, key := range []string{"name", "producers", "consumers"} {
+		if !d.NewValueKnown(key) {
+			return d.SetNewComputed("acls")
+		}
+	}
+
+	desired := topicAcls(
+		d.Get("name").(string),
+		d.Get("producers").(*schema.Set).List(),
+		d.Get("consumers").(*schema.Set).List(),
+	)
+
+	if d.Id() != "" {
+		owned, err := parseAclIDs(d.Get("acls").(*schema.Set).List())
+		if err != nil {
+			return err
+		}
+
+		oldName, _ := d.GetChange("name")
+		_, released := diffAcls(owned, desired)
+		shared, err := sharedGroupAcls(meta.broker, oldName.(string), released)
+		if err != nil {
+			return err
+		}
+
+		desired = append(desired, shared...)
+		sortAcls(desired)
+	}
+
+	return customizeDiffOwnedAcls(d, desired)
+}
+
+// applyTopicAcls creates the ACLs of the producers and consumers of the
+// topic and deletes the ACLs it owned which are no longer generated
+func applyTopicAcls(d *schema.ResourceData, broker *adminBroker) error {
+	topic := d.Get("name").(string)
+	desired := topicAcls(
+		topic,
+		d.Get("producers").(*schema.Set).List(),
+		d.Get("consumers").(*schema.Set).List(),
+	)
+
+	return applyOwnedAcls(d, broker, desired, func(released []helper.Acl) ([]helper.Acl, error) {
+		return releaseTopicAcls(broker, topic, released)
+	})
+}
+
+// releaseTopicAcls deletes ACLs owned by a topic and returns the ones it
+// kept. Consumer groups are often shared between topics, the READ on a group
+// is kept while another topic generates the same group ACL for its consumers.
+func releaseTopicAcls(broker *adminBroker, topic string, acls []helper.Acl) ([]helper.Acl, error) {
+	kept, err := sharedGroupAcls(broker, topic, acls)
+	if err != nil {
+		return nil, err
+	}
+
+	_, released := diffAcls(acls, kept)
+	return kept, deleteAcls(broker, released)
+}
+
+// sharedGroupAcls returns the group ACLs among acls which another topic
+// generates as well. Kafka does not record which resource created an ACL, a
+// group ACL is generated by another topic when its principal is allowed to
+// READ that topic the way a kafka_topic allows it and the group consumes it.
+// A consumer group which has not consumed the other topic yet loses its
+// ACL, the refresh of that topic plans it again.
+func sharedGroupAcls(broker *adminBroker, topic string, acls []helper.Acl) ([]helper.Acl, error) {
+	consumed := make(map[string]map[string]bool)
+
+	var shared []helper.Acl
+	for _, acl := range acls {
+		if acl.ResourceType != sarama.AclResourceGroup {
+			continue
+		}
+
+		reads, err := principalReadTopics(broker, acl.Principal, topic)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(reads) == 0 {
+			continue
+		}
+
+		if _, ok := consumed[acl.ResourceName]; !ok {
+			topics, err := groupTopics(broker, acl.ResourceName)
+			if err != nil {
+				return nil, err
+			}
+			consumed[acl.ResourceName] = topics
+		}
+
+		for _, other := range reads {
+			if consumed[acl.ResourceName][other] {
+				log.Printf("[DEBUG] Kafka: keeping ACL %s, topic %s generates it as well", aclID(acl), other)
+				shared = append(shared, acl)
+				break
+			}
+		}
+	}
+
+	return shared, nil
+}
+
+// principalReadTopics returns the topics other than the given one which the
+// principal is allowed to READ through a literal ACL for every host, the ACL
+// a kafka_topic generates for its consumers
+func principalReadTopics(broker *adminBroker, principal string, topic string) ([]string, error) {
+	acls, err := describeAcls(broker, &sarama.AclFilter{
+		Version:                   1,
+		ResourceType:              sarama.AclResourceTopic,
+		ResourcePatternTypeFilter: sarama.AclPatternAny,
+		Principal:                 &principal,
+		Operation:                 sarama.AclOperationRead,
+		PermissionType:            sarama.AclPermissionAllow,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var topics []string
+	for _, acl := range acls {
+		if acl.ResourceName != topic && acl.PatternType == sarama.AclPatternLiteral && acl.Host == "*" {
+			topics = append(topics, acl.ResourceName)
+		}
+	}
+
+	return topics, nil
+}
+
+// groupTopics returns the topics a consumer group is assigned or committed
+// offsets on. Groups which cannot be described fail the check, the same way
+// they fail the deletion checks.
+func groupTopics(broker *adminBroker, group string) (map[string]bool, error) {
+	found, err := broker.FindCoordinator(&sarama.FindCoordinatorRequest{
+		Version:         1,
+		CoordinatorKey:  group,
+		CoordinatorType: sarama.CoordinatorGroup,
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	if found.Err != sarama.ErrNoError {
+		return nil, fmt.Errorf("Error finding the coordinator of consumer group %s: %s", group, kafkaError(found.Err, found.ErrMsg))
+	}
+
+	coordinator, closeCoordinator, err := routeToPeer(broker, found.Coordinator)
+	if err != nil {
+		return nil, err
+	}
+	defer closeCoordinator()
+
+	described, err := coordinator.DescribeGroups(&sarama.DescribeGroupsRequest{Groups: []string{group}})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	topics := make(map[string]bool)
+	for _, description := range described.Groups {
+		if description.Err != sarama.ErrNoError {
+			return nil, fmt.Errorf("Error describing consumer group %s: %s", group, description.Err)
+		}
+
+		for _, member := range description.Members {
+			assignment, err := member.GetMemberAssignment()
+			if err != nil {
+				return nil, fmt.Errorf("Error decoding the assignment of member %s of consumer group %s: %s", member.MemberId, group, err)
+			}
+
+			if assignment == nil {
+				continue
+			}
+
+			for assigned := range assignment.Topics {
+				topics[assigned] = true
+			}
+		}
+	}
+
+	// version 2 returns the offsets of every topic when none is given
+	offsets, err := coordinator.FetchOffset(&sarama.OffsetFetchRequest{Version: 2, ConsumerGroup: group})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	if offsets.Err != sarama.ErrNoError {
+		return nil, fmt.Errorf("Error fetching the offsets of consumer group %s: %s", group, offsets.Err)
+	}
+
+	for committed, blocks := range offsets.Blocks {
+		for _, block := range blocks {
+			if block.Err == sarama.ErrNoError && block.Offset >= 0 {
+				topics[committed] = true
+			}
+		}
+	}
+
+	return topics, nil
+}
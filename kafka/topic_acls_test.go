@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicAcls(t *testing.T) {
+	producers := []interface{}{
+		map[string]interface{}{"principals": schema.NewSet(schema.HashString, []interface{}{"User:writer"})},
+	}
+	consumers := []interface{}{
+		map[string]interface{}{
+			"principals": schema.NewSet(schema.HashString, []interface{}{"User:reader"}),
+			"groups":     schema.NewSet(schema.HashString, []interface{}{"billing", "audit"}),
+		},
+		// same principal listed twice only yields one READ on the topic
+		map[string]interface{}{
+			"principals": schema.NewSet(schema.HashString, []interface{}{"User:reader"}),
+			"groups":     schema.NewSet(schema.HashString, []interface{}{"billing"}),
+		},
+	}
+
+	ids := aclIDs(topicAcls("orders", producers, consumers))
+	assert.Equal(t, []string{
+		"Group|Literal|User:reader|*|Read|Allow|audit",
+		"Group|Literal|User:reader|*|Read|Allow|billing",
+		"Topic|Literal|User:reader|*|Read|Allow|orders",
+		"Topic|Literal|User:writer|*|Describe|Allow|orders",
+		"Topic|Literal|User:writer|*|Write|Allow|orders",
+	}, ids)
+
+	assert.Empty(t, topicAcls("orders", nil, nil))
+}
+
+func TestParseAclIDs(t *testing.T) {
+	acls, err := parseAclIDs([]interface{}{
+		"Topic|Literal|User:writer|*|Write|Allow|orders",
+		"Group|Literal|User:reader|*|Read|Allow|billing",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, acls, 2)
+	assert.Equal(t, sarama.AclResourceGroup, acls[0].ResourceType)
+	assert.Equal(t, sarama.AclOperationWrite, acls[1].Operation)
+
+	_, err = parseAclIDs([]interface{}{"orders"})
+	assert.Error(t, err)
+}
+
+func TestTopicAclsSchema(t *testing.T) {
+	s := kafkaSchema()
+	assert.True(t, s["acls"].Computed)
+	assert.False(t, s["acls"].Optional)
+	assert.True(t, s["producers"].Optional)
+	assert.True(t, s["consumers"].Optional)
+}
+
+func TestApplyOwnedAclsKeepsRetainedAcls(t *testing.T) {
+	group := "Group|Literal|User:reader|*|Read|Allow|billing"
+	read := "Topic|Literal|User:reader|*|Read|Allow|orders"
+
+	d := schema.TestResourceDataRaw(t, kafkaSchema(), map[string]interface{}{"name": "orders"})
+	d.SetId("orders")
+	d.Set("acls", []interface{}{group, read})
+	d = resourceKafkaTopic().Data(d.State())
+
+	err := applyOwnedAcls(d, nil, nil, func(released []helper.Acl) ([]helper.Acl, error) {
+		assert.Equal(t, []string{group, read}, aclIDs(released))
+		return released[:1], nil
+	})
+	assert.NoError(t, err)
+
+	// the group ACL still exists and stays owned
+	assert.Equal(t, []interface{}{group}, d.Get("acls").(*schema.Set).List())
+}
+
+func TestSharedGroupAcls(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+
+	reads := &sarama.DescribeAclsResponse{
+		Version: 1,
+		ResourceAcls: []*sarama.ResourceAcls{{
+			Resource: sarama.Resource{
+				ResourceType:        sarama.AclResourceTopic,
+				ResourceName:        "payments",
+				ResourcePatternType: sarama.AclPatternLiteral,
+			},
+			Acls: []*sarama.Acl{{
+				Principal:      "User:reader",
+				Host:           "*",
+				Operation:      sarama.AclOperationRead,
+				PermissionType: sarama.AclPermissionAllow,
+			}},
+		}},
+	}
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"DescribeAclsRequest": sarama.NewMockWrapper(reads),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "billing", mock).
+			SetCoordinator(sarama.CoordinatorGroup, "audit", mock),
+		"DescribeGroupsRequest": sarama.NewMockDescribeGroupsResponse(t),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("billing", "payments", 0, 12, "", sarama.ErrNoError).
+			SetOffset("audit", "orders", 0, 3, "", sarama.ErrNoError),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	acls, err := parseAclIDs([]interface{}{
+		"Group|Literal|User:reader|*|Read|Allow|billing",
+		"Group|Literal|User:reader|*|Read|Allow|audit",
+		"Topic|Literal|User:reader|*|Read|Allow|orders",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// payments is consumed through billing only, audit is only used for orders
+	shared, err := sharedGroupAcls(newAdminScheduler(1).broker(broker), "orders", acls)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Group|Literal|User:reader|*|Read|Allow|billing"}, aclIDs(shared))
+}
@@ -0,0 +1,316 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// brokerRack locates a broker for replica placement
+type brokerRack struct {
+	id   int32
+	rack string
+}
+
+// partitionsDecreased reports whether the diff reduces the partitions of an existing topic
+func partitionsDecreased(d *schema.ResourceDiff) bool {
+	if d.Id() == "" || !d.HasChange("partitions") {
+		return false
+	}
+
+	oldVal, newVal := d.GetChange("partitions")
+	return newVal.(int) < oldVal.(int)
+}
+
+// customizeDiffPartitions fails the plan when partitions are reduced, since
+// Kafka cannot remove partitions, unless the topic may be replaced and
+// force_destroy is already applied
+func customizeDiffPartitions(d *schema.ResourceDiff) error {
+	if !partitionsDecreased(d) {
+		return nil
+	}
+
+	if !d.Get("allow_recreate_on_partition_decrease").(bool) {
+		oldVal, newVal := d.GetChange("partitions")
+		return fmt.Errorf(
+			"Topic %s cannot go from %d to %d partitions, Kafka cannot remove partitions. "+
+				"Set allow_recreate_on_partition_decrease to replace the topic and lose its records",
+			d.Id(), oldVal, newVal,
+		)
+	}
+
+	// The old topic is destroyed with the force_destroy of the state, the
+	// guard against deleting used topics would only fail at apply time
+	forced, _ := d.GetChange("force_destroy")
+	if !forced.(bool) {
+		return fmt.Errorf(
+			"Topic %s has to be replaced to reduce its partitions, which deletes its records. "+
+				"Apply force_destroy before reducing the partitions",
+			d.Id(),
+		)
+	}
+
+	return d.ForceNew("partitions")
+}
+
+// customizeDiffReplicaAssignment fails the plan when a configured
+// replica_assignment does not match partitions and replication_factor, or
+// moves the replicas of partitions which already exist. An unchanged
+// assignment was read from the cluster and is left to newPartitionsAssignment
+func customizeDiffReplicaAssignment(d *schema.ResourceDiff) error {
+	if !d.NewValueKnown("replica_assignment") || !d.NewValueKnown("partitions") || !d.NewValueKnown("replication_factor") {
+		return nil
+	}
+
+	blocks := d.Get("replica_assignment").([]interface{})
+	if len(blocks) == 0 || (d.Id() != "" && !d.HasChange("replica_assignment")) {
+		return nil
+	}
+
+	err := validateReplicaAssignment(blocks, d.Get("partitions").(int), d.Get("replication_factor").(int))
+	if err != nil {
+		return err
+	}
+
+	// a replaced topic is created with the new assignment
+	if d.Id() == "" || partitionsDecreased(d) {
+		return nil
+	}
+
+	oldVal, _ := d.GetChange("replica_assignment")
+	return ensureExistingPartitionsKept(
+		helper.ReplicaAssignment(oldVal.([]interface{})),
+		helper.ReplicaAssignment(blocks),
+	)
+}
+
+// validateReplicaAssignment checks that replica_assignment lists every
+// partition from 0 to partitions-1 once, each with replicationFactor distinct
+// replicas
+func validateReplicaAssignment(blocks []interface{}, partitions int, replicationFactor int) error {
+	listed := make(map[int]bool, len(blocks))
+	for _, b := range blocks {
+		block := b.(map[string]interface{})
+		partition := block["partition"].(int)
+		replicas := block["replicas"].([]interface{})
+
+		if partition < 0 || partition >= partitions {
+			return fmt.Errorf("replica_assignment lists partition %d, the topic has partitions 0 to %d", partition, partitions-1)
+		}
+
+		if listed[partition] {
+			return fmt.Errorf("replica_assignment lists partition %d more than once", partition)
+		}
+		listed[partition] = true
+
+		if len(replicas) != replicationFactor {
+			return fmt.Errorf("Partition %d must have %d replicas, got %v", partition, replicationFactor, replicas)
+		}
+
+		brokers := make(map[int]bool, len(replicas))
+		for _, replica := range replicas {
+			if brokers[replica.(int)] {
+				return fmt.Errorf("Partition %d lists broker %d more than once", partition, replica)
+			}
+			brokers[replica.(int)] = true
+		}
+	}
+
+	for partition := 0; partition < partitions; partition++ {
+		if !listed[partition] {
+			return fmt.Errorf("replica_assignment is missing partition %d, it must list every partition from 0 to %d", partition, partitions-1)
+		}
+	}
+
+	return nil
+}
+
+// ensureExistingPartitionsKept refuses to move the replicas of existing
+// partitions, reassignment is not supported
+func ensureExistingPartitionsKept(existing map[int32][]int32, configured map[int32][]int32) error {
+	partitions := make([]int, 0, len(existing))
+	for partition := range existing {
+		partitions = append(partitions, int(partition))
+	}
+	sort.Ints(partitions)
+
+	for _, partition := range partitions {
+		current := existing[int32(partition)]
+		if replicas, ok := configured[int32(partition)]; ok && !reflect.DeepEqual(replicas, current) {
+			return fmt.Errorf("Partition %d is assigned to %v, reassigning existing partitions is not supported", partition, current)
+		}
+	}
+
+	return nil
+}
+
+// flattenReplicaAssignment converts replicas by partition into replica_assignment blocks
+func flattenReplicaAssignment(assignment map[int32][]int32) []interface{} {
+	partitions := make([]int, 0, len(assignment))
+	for partition := range assignment {
+		partitions = append(partitions, int(partition))
+	}
+	sort.Ints(partitions)
+
+	blocks := make([]interface{}, 0, len(partitions))
+	for _, partition := range partitions {
+		replicas := make([]interface{}, 0, len(assignment[int32(partition)]))
+		for _, replica := range assignment[int32(partition)] {
+			replicas = append(replicas, int(replica))
+		}
+
+		blocks = append(blocks, map[string]interface{}{
+			"partition": partition,
+			"replicas":  replicas,
+		})
+	}
+
+	return blocks
+}
+
+// newPartitionsAssignment returns the replicas of the partitions added to a
+// topic, either taken from replica_assignment or computed from the current
+// placement and the racks of the brokers
+func newPartitionsAssignment(broker *adminBroker, topic string, configured []interface{}, count int, replicationFactor int) ([][]int32, error) {
+	metadata, err := broker.GetMetadata(r.GetKafkaTopicMetadataRequest(topic))
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	return planTopicPartitions(metadata, topic, helper.ReplicaAssignment(configured), count, replicationFactor)
+}
+
+// planTopicPartitions is planNewPartitions for a topic, its existing
+// replicas and the brokers being taken from metadata
+func planTopicPartitions(metadata *sarama.MetadataResponse, topic string, configured map[int32][]int32, count int, replicationFactor int) ([][]int32, error) {
+	existing := make(map[int32][]int32)
+	for _, t := range metadata.Topics {
+		if t.Name != topic {
+			continue
+		}
+
+		for _, partition := range t.Partitions {
+			existing[partition.ID] = partition.Replicas
+		}
+	}
+
+	brokers := make([]brokerRack, 0, len(metadata.Brokers))
+	for _, b := range metadata.Brokers {
+		brokers = append(brokers, brokerRack{id: b.ID(), rack: b.Rack()})
+	}
+
+	return planNewPartitions(existing, configured, brokers, count, replicationFactor)
+}
+
+// planNewPartitions validates the configured assignment of the new partitions
+// or computes one when it is not configured
+func planNewPartitions(existing map[int32][]int32, configured map[int32][]int32, brokers []brokerRack, count int, replicationFactor int) ([][]int32, error) {
+	if err := ensureExistingPartitionsKept(existing, configured); err != nil {
+		return nil, err
+	}
+
+	var fromConfig, missing int
+	for partition := int32(0); partition < int32(count); partition++ {
+		replicas, ok := configured[partition]
+		if _, exists := existing[partition]; exists {
+			continue
+		}
+
+		if !ok {
+			missing++
+			continue
+		}
+
+		if len(replicas) != replicationFactor {
+			return nil, fmt.Errorf("Partition %d must have %d replicas, got %v", partition, replicationFactor, replicas)
+		}
+		fromConfig++
+	}
+
+	if fromConfig > 0 && missing > 0 {
+		return nil, fmt.Errorf("replica_assignment must either cover all new partitions or none of them")
+	}
+
+	if fromConfig > 0 {
+		assignment := make([][]int32, 0, fromConfig)
+		for partition := int32(len(existing)); partition < int32(count); partition++ {
+			assignment = append(assignment, configured[partition])
+		}
+		return assignment, nil
+	}
+
+	return assignNewPartitions(existing, brokers, count, replicationFactor)
+}
+
+// assignNewPartitions spreads the replicas of new partitions over the least
+// loaded brokers, never placing two replicas of a partition in the same rack
+// while another rack is available
+func assignNewPartitions(existing map[int32][]int32, brokers []brokerRack, count int, replicationFactor int) ([][]int32, error) {
+	if replicationFactor > len(brokers) {
+		return nil, fmt.Errorf("Replication factor %d is larger than the %d available brokers", replicationFactor, len(brokers))
+	}
+
+	leaders := make(map[int32]int)
+	replicas := make(map[int32]int)
+	for _, assigned := range existing {
+		for i, replica := range assigned {
+			if i == 0 {
+				leaders[replica]++
+			}
+			replicas[replica]++
+		}
+	}
+
+	sorted := make([]brokerRack, len(brokers))
+	copy(sorted, brokers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].id < sorted[j].id })
+
+	var assignment [][]int32
+	for partition := len(existing); partition < count; partition++ {
+		chosen := make([]int32, 0, replicationFactor)
+		usedBrokers := make(map[int32]bool)
+		usedRacks := make(map[string]bool)
+
+		for len(chosen) < replicationFactor {
+			load := replicas
+			if len(chosen) == 0 {
+				load = leaders
+			}
+
+			var best *brokerRack
+			bestRackFree := false
+			for i := range sorted {
+				candidate := &sorted[i]
+				if usedBrokers[candidate.id] {
+					continue
+				}
+
+				rackFree := candidate.rack == "" || !usedRacks[candidate.rack]
+				if best == nil ||
+					(rackFree && !bestRackFree) ||
+					(rackFree == bestRackFree && load[candidate.id] < load[best.id]) {
+					best = candidate
+					bestRackFree = rackFree
+				}
+			}
+
+			chosen = append(chosen, best.id)
+			usedBrokers[best.id] = true
+			usedRacks[best.rack] = true
+			replicas[best.id]++
+			if len(chosen) == 1 {
+				leaders[best.id]++
+			}
+		}
+
+		assignment = append(assignment, chosen)
+	}
+
+	return assignment, nil
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlattenReplicaAssignment(t *testing.T) {
+	blocks := flattenReplicaAssignment(map[int32][]int32{1: {2, 3}, 0: {1, 2}})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"partition": 0, "replicas": []interface{}{1, 2}},
+		map[string]interface{}{"partition": 1, "replicas": []interface{}{2, 3}},
+	}, blocks)
+}
+
+func TestAssignNewPartitions(t *testing.T) {
+	existing := map[int32][]int32{0: {1, 2}, 1: {2, 1}}
+	brokers := []brokerRack{{id: 1, rack: "a"}, {id: 2, rack: "a"}, {id: 3, rack: "b"}}
+
+	assignment, err := assignNewPartitions(existing, brokers, 4, 2)
+	assert.Nil(t, err)
+	assert.Len(t, assignment, 2)
+
+	// broker 3 leads nothing yet and is the only broker of rack b
+	assert.Equal(t, int32(3), assignment[0][0])
+	for _, replicas := range assignment {
+		assert.Len(t, replicas, 2)
+		assert.NotEqual(t, replicas[0], replicas[1])
+		assert.Contains(t, replicas, int32(3), "each partition spans both racks")
+	}
+
+	_, err = assignNewPartitions(existing, brokers, 4, 4)
+	assert.NotNil(t, err)
+}
+
+func TestPlanNewPartitions(t *testing.T) {
+	existing := map[int32][]int32{0: {1, 2}}
+	brokers := []brokerRack{{id: 1}, {id: 2}, {id: 3}}
+
+	configured := map[int32][]int32{0: {1, 2}, 1: {3, 1}}
+	assignment, err := planNewPartitions(existing, configured, brokers, 2, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int32{{3, 1}}, assignment)
+
+	assignment, err = planNewPartitions(existing, map[int32][]int32{0: {1, 2}}, brokers, 2, 2)
+	assert.Nil(t, err)
+	assert.Len(t, assignment, 1)
+
+	_, err = planNewPartitions(existing, map[int32][]int32{0: {2, 1}, 1: {3, 1}}, brokers, 2, 2)
+	assert.NotNil(t, err)
+
+	_, err = planNewPartitions(existing, map[int32][]int32{1: {3}}, brokers, 2, 2)
+	assert.NotNil(t, err)
+
+	_, err = planNewPartitions(existing, map[int32][]int32{1: {3, 1}}, brokers, 3, 2)
+	assert.NotNil(t, err)
+}
+
+func TestValidateReplicaAssignment(t *testing.T) {
+	block := func(partition int, replicas ...interface{}) interface{} {
+		return map[string]interface{}{"partition": partition, "replicas": replicas}
+	}
+
+	assert.Nil(t, validateReplicaAssignment([]interface{}{block(1, 2, 3), block(0, 1, 2)}, 2, 2))
+
+	// gap and missing partitions
+	assert.NotNil(t, validateReplicaAssignment([]interface{}{block(0, 1, 2), block(2, 2, 3)}, 3, 2))
+	assert.NotNil(t, validateReplicaAssignment([]interface{}{block(0, 1, 2)}, 2, 2))
+	// more partitions than the topic
+	assert.NotNil(t, validateReplicaAssignment([]interface{}{block(0, 1, 2), block(1, 2, 3)}, 1, 2))
+	assert.NotNil(t, validateReplicaAssignment([]interface{}{block(0, 1, 2), block(0, 2, 3)}, 1, 2))
+	// uneven replication factor
+	assert.NotNil(t, validateReplicaAssignment([]interface{}{block(0, 1, 2), block(1, 3)}, 2, 2))
+	assert.NotNil(t, validateReplicaAssignment([]interface{}{block(0, 1, 1)}, 1, 2))
+}
+
+func TestEnsureExistingPartitionsKept(t *testing.T) {
+	existing := map[int32][]int32{0: {1, 2}, 1: {2, 3}}
+
+	assert.Nil(t, ensureExistingPartitionsKept(existing, map[int32][]int32{0: {1, 2}, 1: {2, 3}, 2: {3, 1}}))
+	assert.Nil(t, ensureExistingPartitionsKept(existing, map[int32][]int32{}))
+	assert.EqualError(t,
+		ensureExistingPartitionsKept(existing, map[int32][]int32{0: {1, 2}, 1: {3, 2}}),
+		"Partition 1 is assigned to [2 3], reassigning existing partitions is not supported",
+	)
+}
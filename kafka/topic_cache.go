@@ -0,0 +1,312 @@
+package kafka
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// metadataApiKey identifies Metadata requests in an ApiVersionsResponse
+const metadataApiKey int16 = 3
+
+// topicIDMetadataVersion is the first Metadata version returning topic IDs,
+// clusters before 2.8 are sent the last version before the flexible ones
+const (
+	topicIDMetadataVersion int16 = 10
+	legacyMetadataVersion  int16 = 5
+)
+
+// topicCacheWindow is how long a lookup waits for the lookups of other
+// resources to join it in a single fetch
+const topicCacheWindow = 20 * time.Millisecond
+
+// cachedTopic is the state of a topic as last read from the cluster
+type cachedTopic struct {
+	metadata *sarama.TopicMetadata
+	configs  map[string]string
+}
+
+// topicCache answers topic reads for the duration of a Terraform run. Only
+// the topics read by resources are fetched: lookups arriving within
+// topicCacheWindow are merged into batched MetadataRequests and
+// DescribeConfigsRequests, instead of one of each per resource. Topics which
+// are created, altered or deleted are invalidated and read again on their
+// next lookup. The lock is never held during requests.
+type topicCache struct {
+	mu      sync.Mutex
+	version int16
+	topics  map[string]*cachedTopic
+	known   map[string]bool
+	pending *pendingTopicFetch
+	all     []string
+
+	// topics invalidated while being fetched stay unknown
+	invalidated map[string]bool
+}
+
+// pendingTopicFetch is a fetch still open to the topics of other lookups
+type pendingTopicFetch struct {
+	broker *adminBroker
+	names  map[string]bool
+	done   chan struct{}
+	err    error
+}
+
+func newTopicCache() *topicCache {
+	return &topicCache{
+		topics:      make(map[string]*cachedTopic),
+		known:       make(map[string]bool),
+		invalidated: make(map[string]bool),
+	}
+}
+
+// lookup returns the cached state of the topics, topics missing from the
+// result do not exist on the cluster
+func (c *topicCache) lookup(broker *adminBroker, names []string) (map[string]*cachedTopic, error) {
+	c.mu.Lock()
+
+	var unknown []string
+	for _, name := range names {
+		if !c.known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		fetch := c.join(broker, unknown)
+		c.mu.Unlock()
+
+		<-fetch.done
+		if fetch.err != nil {
+			return nil, fetch.err
+		}
+
+		c.mu.Lock()
+	}
+	defer c.mu.Unlock()
+
+	topics := make(map[string]*cachedTopic, len(names))
+	for _, name := range names {
+		if topic, ok := c.topics[name]; ok {
+			topics[name] = topic
+		}
+	}
+
+	return topics, nil
+}
+
+// join adds names to the pending fetch, or opens a new one which is sent
+// after topicCacheWindow. The lock must be held.
+func (c *topicCache) join(broker *adminBroker, names []string) *pendingTopicFetch {
+	fetch := c.pending
+	if fetch == nil {
+		fetch = &pendingTopicFetch{
+			broker: broker,
+			names:  make(map[string]bool),
+			done:   make(chan struct{}),
+		}
+		c.pending = fetch
+		go c.run(fetch)
+	}
+
+	for _, name := range names {
+		fetch.names[name] = true
+	}
+
+	return fetch
+}
+
+// run sends a pending fetch once its window has passed
+func (c *topicCache) run(fetch *pendingTopicFetch) {
+	time.Sleep(topicCacheWindow)
+
+	c.mu.Lock()
+	c.pending = nil
+	names := make([]string, 0, len(fetch.names))
+	for name := range fetch.names {
+		names = append(names, name)
+		delete(c.invalidated, name)
+	}
+	c.mu.Unlock()
+	sort.Strings(names)
+
+	fetch.err = c.fetch(fetch.broker, names)
+	close(fetch.done)
+}
+
+// names lists the topics which exist on the cluster
+func (c *topicCache) names(broker *adminBroker) ([]string, error) {
+	c.mu.Lock()
+	all := c.all
+	c.mu.Unlock()
+
+	if all != nil {
+		return all, nil
+	}
+
+	version, err := c.metadataVersion(broker)
+	if err != nil {
+		return nil, err
+	}
+
+	request := r.GetKafkaAllTopicsMetadataRequest()
+	request.Version = version
+
+	response, err := broker.GetMetadata(request)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	all = make([]string, 0, len(response.Topics))
+	for _, t := range response.Topics {
+		if t.Err != sarama.ErrUnknownTopicOrPartition {
+			all = append(all, t.Name)
+		}
+	}
+	sort.Strings(all)
+
+	c.mu.Lock()
+	c.all = all
+	c.mu.Unlock()
+
+	return all, nil
+}
+
+// invalidate marks topics as changed on the cluster
+func (c *topicCache) invalidate(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range names {
+		delete(c.known, name)
+		c.invalidated[name] = true
+	}
+	c.all = nil
+}
+
+// metadataVersion negotiates the Metadata version on first use
+func (c *topicCache) metadataVersion(broker *adminBroker) (int16, error) {
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+
+	if version != 0 {
+		return version, nil
+	}
+
+	version, err := negotiateMetadataVersion(broker)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.version = version
+	c.mu.Unlock()
+
+	return version, nil
+}
+
+// fetch reads the topics and their configs from the cluster, in batches
+func (c *topicCache) fetch(broker *adminBroker, names []string) error {
+	version, err := c.metadataVersion(broker)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Kafka: caching %d topics", len(names))
+
+	for _, batch := range topicBatches(names) {
+		request := r.GetKafkaTopicsMetadataRequest(batch)
+		request.Version = version
+
+		response, err := broker.GetMetadata(request)
+		if err != nil {
+			log.Println(err.Error())
+			return err
+		}
+
+		topics, err := describeTopics(broker, response)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		for _, name := range batch {
+			if topic, ok := topics[name]; ok {
+				c.topics[name] = topic
+			} else {
+				delete(c.topics, name)
+			}
+			c.known[name] = !c.invalidated[name]
+		}
+		c.mu.Unlock()
+	}
+
+	return nil
+}
+
+// describeTopics reads the configs of the topics of a metadata response
+func describeTopics(broker *adminBroker, response *sarama.MetadataResponse) (map[string]*cachedTopic, error) {
+	topics := make(map[string]*cachedTopic, len(response.Topics))
+	existing := make([]string, 0, len(response.Topics))
+	for _, t := range response.Topics {
+		if t.Err == sarama.ErrUnknownTopicOrPartition {
+			continue
+		}
+
+		topics[t.Name] = &cachedTopic{metadata: t, configs: make(map[string]string)}
+		existing = append(existing, t.Name)
+	}
+	sort.Strings(existing)
+
+	if len(existing) == 0 {
+		return topics, nil
+	}
+
+	described, err := broker.DescribeConfigs(r.GetKafkaTopicsConfigsRequest(existing, r.ValidConfigNames()))
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	for _, resource := range described.Resources {
+		topic, ok := topics[resource.Name]
+		if !ok {
+			continue
+		}
+
+		for _, config := range resource.Configs {
+			topic.configs[config.Name] = config.Value
+		}
+	}
+
+	return topics, nil
+}
+
+// negotiateMetadataVersion picks the Metadata version sent to the cluster,
+// topic IDs are only returned from version 10
+func negotiateMetadataVersion(broker *adminBroker) (int16, error) {
+	response, err := broker.ApiVersions(&sarama.ApiVersionsRequest{})
+	if err != nil {
+		log.Println(err.Error())
+		return 0, err
+	}
+
+	if response.ErrorCode != int16(sarama.ErrNoError) {
+		err := sarama.KError(response.ErrorCode)
+		log.Println(err.Error())
+		return 0, err
+	}
+
+	for _, key := range response.ApiKeys {
+		if key.ApiKey == metadataApiKey && key.MaxVersion >= topicIDMetadataVersion {
+			return topicIDMetadataVersion, nil
+		}
+	}
+
+	return legacyMetadataVersion, nil
+}
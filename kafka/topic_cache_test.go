@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockTopicBroker(t *testing.T) (*sarama.MockBroker, *adminBroker) {
+	mock := sarama.NewMockBroker(t, 1)
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(mock.Addr(), mock.BrokerID()).
+			SetLeader("mytopic", 0, mock.BrokerID()).
+			SetLeader("mytopic", 1, mock.BrokerID()),
+		"DescribeConfigsRequest": sarama.NewMockDescribeConfigsResponse(t),
+		"ApiVersionsRequest":     mockApiVersions(t, topicIDMetadataVersion),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+
+	return mock, newAdminScheduler(1).broker(broker)
+}
+
+// mockApiVersions advertises Metadata up to maxVersion
+func mockApiVersions(t *testing.T, maxVersion int16) *sarama.MockApiVersionsResponse {
+	return sarama.NewMockApiVersionsResponse(t).SetApiKeys([]sarama.ApiVersionsResponseKey{
+		{ApiKey: metadataApiKey, MinVersion: 0, MaxVersion: maxVersion},
+	})
+}
+
+// requestCounts counts the requests received by the mock broker by type
+func requestCounts(mock *sarama.MockBroker) map[string]int {
+	counts := make(map[string]int)
+	for _, exchange := range mock.History() {
+		counts[fmt.Sprintf("%T", exchange.Request)]++
+	}
+
+	return counts
+}
+
+func TestTopicCacheLookup(t *testing.T) {
+	mock, broker := newMockTopicBroker(t)
+	defer mock.Close()
+	defer broker.Close()
+
+	cache := newTopicCache()
+
+	topics, err := cache.lookup(broker, []string{"mytopic", "missing"})
+	assert.NoError(t, err)
+	assert.Len(t, topics, 1)
+	assert.Len(t, topics["mytopic"].metadata.Partitions, 2)
+
+	// answered from the cache
+	_, err = cache.lookup(broker, []string{"mytopic"})
+	assert.NoError(t, err)
+
+	counts := requestCounts(mock)
+	assert.Equal(t, 1, counts["*sarama.MetadataRequest"])
+	assert.Equal(t, 1, counts["*sarama.DescribeConfigsRequest"])
+
+	// the other topics of the cluster are listed without their configs
+	names, err := cache.names(broker)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mytopic"}, names)
+
+	_, err = cache.names(broker)
+	assert.NoError(t, err)
+
+	counts = requestCounts(mock)
+	assert.Equal(t, 2, counts["*sarama.MetadataRequest"])
+	assert.Equal(t, 1, counts["*sarama.DescribeConfigsRequest"])
+}
+
+func TestTopicCacheBatchesLookups(t *testing.T) {
+	mock, broker := newMockTopicBroker(t)
+	defer mock.Close()
+	defer broker.Close()
+
+	cache := newTopicCache()
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"mytopic", "missing", "mytopic"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			_, err := cache.lookup(broker, []string{name})
+			assert.NoError(t, err)
+		}(name)
+	}
+	wg.Wait()
+
+	counts := requestCounts(mock)
+	assert.Equal(t, 1, counts["*sarama.MetadataRequest"])
+	assert.Equal(t, 1, counts["*sarama.DescribeConfigsRequest"])
+}
+
+func TestTopicCacheInvalidate(t *testing.T) {
+	mock, broker := newMockTopicBroker(t)
+	defer mock.Close()
+	defer broker.Close()
+
+	cache := newTopicCache()
+
+	_, err := cache.lookup(broker, []string{"mytopic"})
+	assert.NoError(t, err)
+
+	cache.invalidate("mytopic")
+	assert.False(t, cache.known["mytopic"])
+
+	topics, err := cache.lookup(broker, []string{"mytopic"})
+	assert.NoError(t, err)
+	assert.Len(t, topics, 1)
+	assert.True(t, cache.known["mytopic"])
+
+	counts := requestCounts(mock)
+	assert.Equal(t, 2, counts["*sarama.MetadataRequest"])
+	assert.Equal(t, 2, counts["*sarama.DescribeConfigsRequest"])
+}
+
+func TestTopicCacheMetadataVersion(t *testing.T) {
+	mock, broker := newMockTopicBroker(t)
+	defer mock.Close()
+	defer broker.Close()
+
+	cache := newTopicCache()
+
+	_, err := cache.lookup(broker, []string{"mytopic"})
+	assert.NoError(t, err)
+	assert.Equal(t, topicIDMetadataVersion, cache.version)
+
+	cache.invalidate("mytopic")
+	_, err = cache.lookup(broker, []string{"mytopic"})
+	assert.NoError(t, err)
+
+	for _, exchange := range mock.History() {
+		if request, ok := exchange.Request.(*sarama.MetadataRequest); ok {
+			assert.Equal(t, topicIDMetadataVersion, request.Version)
+		}
+	}
+	assert.Equal(t, 1, requestCounts(mock)["*sarama.ApiVersionsRequest"])
+
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ApiVersionsRequest": mockApiVersions(t, 9),
+	})
+	version, err := negotiateMetadataVersion(broker)
+	assert.NoError(t, err)
+	assert.Equal(t, legacyMetadataVersion, version)
+}
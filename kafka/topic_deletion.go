@@ -0,0 +1,554 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// Supported values of the deletion_mode attribute
+const (
+	deletionModeFail    = "fail"
+	deletionModeAbandon = "abandon"
+	deletionModeRetire  = "retire"
+)
+
+// retiredTopicRetentionMs is the retention applied to topics retired instead of deleted
+const retiredTopicRetentionMs = "60000"
+
+func deletionModes() []string {
+	return []string{deletionModeFail, deletionModeAbandon, deletionModeRetire}
+}
+
+func validateDeletionMode(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	for _, mode := range deletionModes() {
+		if value == mode {
+			return
+		}
+	}
+
+	errors = append(errors, fmt.Errorf("%s must be one of %v, got %q", k, deletionModes(), value))
+	return
+}
+
+// topicDeletionDisabled checks delete.topic.enable on the controller, which
+// is the broker in charge of deleting topics
+func topicDeletionDisabled(broker *adminBroker, topic string) (bool, error) {
+	metadata, err := broker.GetMetadata(r.GetKafkaTopicMetadataRequest(topic))
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
+	}
+
+	var controller *sarama.Broker
+	for _, b := range metadata.Brokers {
+		if b.ID() == metadata.ControllerID {
+			controller = b
+		}
+	}
+
+	// deleting without knowing the setting could silently keep the topic
+	if controller == nil {
+		return false, fmt.Errorf("Controller %d not found in metadata, cannot check delete.topic.enable", metadata.ControllerID)
+	}
+
+	if err := openBroker(controller); err != nil {
+		return false, err
+	}
+	defer controller.Close()
+
+	request := r.GetKafkaBrokerConfigsRequest(controller.ID(), []string{"delete.topic.enable"})
+	response, err := broker.peer(controller).DescribeConfigs(request)
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
+	}
+
+	for _, resource := range response.Resources {
+		if resource.ErrorCode != 0 {
+			return false, fmt.Errorf("Error describing delete.topic.enable on broker %d: %s", controller.ID(), kafkaError(sarama.KError(resource.ErrorCode), &resource.ErrorMsg))
+		}
+
+		for _, config := range resource.Configs {
+			if config.Name == "delete.topic.enable" {
+				return config.Value == "false", nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// retireTopic keeps the topic in place but shrinks its retention so that the
+// data expires, this is the closest thing to a deletion on such clusters.
+// Only retention.ms is set, the other overrides of the topic are left alone.
+func retireTopic(broker *adminBroker, topic string) error {
+	retention := retiredTopicRetentionMs
+	request := r.IncrementalAlterResourceConfigsRequest(sarama.TopicResource, topic, map[string]*string{"retention.ms": &retention})
+
+	response, err := broker.IncrementalAlterConfigs(request)
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	for _, resource := range response.Resources {
+		if resource.ErrorCode != 0 {
+			return fmt.Errorf("Error retiring topic %s: %s", topic, resource.ErrorMsg)
+		}
+	}
+
+	return nil
+}
+
+// handleDeletionDisabled applies the deletion_mode of a topic which cannot be
+// deleted because the cluster runs with delete.topic.enable=false
+func handleDeletionDisabled(broker *adminBroker, topic string, mode string) error {
+	switch mode {
+	case deletionModeAbandon:
+		log.Printf("[WARN] Kafka: topic deletion is disabled, topic %s is only removed from state", topic)
+		return nil
+	case deletionModeRetire:
+		log.Printf("[WARN] Kafka: topic deletion is disabled, retiring topic %s with retention.ms=%s", topic, retiredTopicRetentionMs)
+		return retireTopic(broker, topic)
+	default:
+		return fmt.Errorf(
+			"Topic %s cannot be deleted: topic deletion is disabled on the cluster (delete.topic.enable=false). "+
+				"Set deletion_mode to %q to only remove it from state or %q to shrink its retention",
+			topic, deletionModeAbandon, deletionModeRetire,
+		)
+	}
+}
+
+// deletionProtectionGetter is satisfied by both schema.ResourceData and schema.ResourceDiff
+type deletionProtectionGetter interface {
+	GetOkExists(string) (interface{}, bool)
+}
+
+// topicDeletionProtected resolves deletion_protection of a topic, falling
+// back to the provider default when the topic does not set it
+func topicDeletionProtected(d deletionProtectionGetter, meta *providerMeta) bool {
+	if v, ok := d.GetOkExists("deletion_protection"); ok {
+		return v.(bool)
+	}
+
+	return meta.deletionProtection
+}
+
+// topicReplaced reports whether the diff destroys and recreates the topic
+func topicReplaced(d *schema.ResourceDiff) bool {
+	if d.Id() == "" {
+		return false
+	}
+
+	// a rename destroys the old topic, even when its records are migrated
+	if d.HasChange("name") || partitionsDecreased(d) {
+		return true
+	}
+
+	// planned by customizeDiffTopicID for topics recreated out of band
+	recorded, current := d.GetChange("topic_id")
+	return topicRecreated(recorded.(string), current.(string))
+}
+
+// customizeDiffDeletionProtection fails the plan of protected topics which
+// would be replaced. Terraform does not diff resources removed from the
+// configuration, their destruction is refused in resourceKafkaTopicDelete.
+func customizeDiffDeletionProtection(d *schema.ResourceDiff, meta *providerMeta) error {
+	if !topicReplaced(d) {
+		return nil
+	}
+
+	// Turning the protection off has to be applied before the replacement
+	protected, _ := d.GetChange("deletion_protection")
+	if protected.(bool) || topicDeletionProtected(d, meta) {
+		return fmt.Errorf("Topic %s has deletion_protection enabled and cannot be replaced", d.Id())
+	}
+
+	return nil
+}
+
+// ensureTopicUnused refuses the deletion of topics which still hold records
+// or which consumer groups are still reading
+func ensureTopicUnused(broker *adminBroker, topic string) error {
+	failures := make(map[string]error)
+	ensureTopicsUnused(broker, []string{topic}, failures)
+
+	return failures[topic]
+}
+
+// ensureTopicsUnused returns the topics which neither hold records nor are
+// read by consumer groups, the others are recorded in failures. A batch of
+// topics takes a single metadata request, one pair of offsets requests per
+// leader and one pass over the consumer groups of every broker.
+func ensureTopicsUnused(broker *adminBroker, topics []string, failures map[string]error) []string {
+	var unused []string
+
+	for _, batch := range topicBatches(topics) {
+		metadata, err := broker.GetMetadata(r.GetKafkaTopicsMetadataRequest(batch))
+		if err != nil {
+			log.Println(err.Error())
+			recordTopicsFailure(failures, batch, err)
+			continue
+		}
+
+		for _, topic := range topicsHoldingRecords(broker, metadata, batch, failures) {
+			failures[topic] = fmt.Errorf("Topic %s still holds records, set force_destroy to delete it anyway", topic)
+		}
+
+		consumers := topicsConsumerGroups(broker, metadata, remainingTopics(batch, failures), failures)
+		for _, topic := range remainingTopics(batch, failures) {
+			if groups := consumers[topic]; len(groups) > 0 {
+				failures[topic] = topicConsumedError(topic, groups)
+				continue
+			}
+
+			unused = append(unused, topic)
+		}
+	}
+
+	return unused
+}
+
+// ensureTopicNotConsumed refuses the removal of topics which consumer groups
+// are still reading
+func ensureTopicNotConsumed(broker *adminBroker, metadata *sarama.MetadataResponse, topic string) error {
+	failures := make(map[string]error)
+	consumers := topicsConsumerGroups(broker, metadata, []string{topic}, failures)
+	if err := failures[topic]; err != nil {
+		return err
+	}
+
+	if groups := consumers[topic]; len(groups) > 0 {
+		return topicConsumedError(topic, groups)
+	}
+
+	return nil
+}
+
+func topicConsumedError(topic string, groups []string) error {
+	return fmt.Errorf("Topic %s is used by consumer groups %v, set force_destroy to delete it anyway", topic, groups)
+}
+
+// remainingTopics drops the topics which already failed
+func remainingTopics(topics []string, failures map[string]error) []string {
+	var remaining []string
+	for _, topic := range topics {
+		if _, failed := failures[topic]; !failed {
+			remaining = append(remaining, topic)
+		}
+	}
+
+	return remaining
+}
+
+// topicPartitions returns the partition ids of the topic, grouped by leader
+func topicPartitions(metadata *sarama.MetadataResponse, topic string) (map[int32][]int32, []int32) {
+	byLeader := make(map[int32][]int32)
+	var partitions []int32
+
+	for _, t := range metadata.Topics {
+		if t.Name != topic {
+			continue
+		}
+
+		for _, partition := range t.Partitions {
+			byLeader[partition.Leader] = append(byLeader[partition.Leader], partition.ID)
+			partitions = append(partitions, partition.ID)
+		}
+	}
+
+	return byLeader, partitions
+}
+
+// leaderlessPartitions returns the partitions whose leader is offline or
+// unknown, their offsets cannot be checked
+func leaderlessPartitions(metadata *sarama.MetadataResponse, byLeader map[int32][]int32) []int32 {
+	brokers := make(map[int32]bool, len(metadata.Brokers))
+	for _, b := range metadata.Brokers {
+		brokers[b.ID()] = true
+	}
+
+	var leaderless []int32
+	for leader, partitions := range byLeader {
+		if !brokers[leader] {
+			leaderless = append(leaderless, partitions...)
+		}
+	}
+
+	sort.Slice(leaderless, func(i, j int) bool { return leaderless[i] < leaderless[j] })
+	return leaderless
+}
+
+// topicHoldsRecords compares the earliest and latest offsets of every
+// partition of a topic on its leader
+func topicHoldsRecords(broker *adminBroker, metadata *sarama.MetadataResponse, topic string) (bool, error) {
+	failures := make(map[string]error)
+	holding := topicsHoldingRecords(broker, metadata, []string{topic}, failures)
+
+	return len(holding) > 0, failures[topic]
+}
+
+// topicsHoldingRecords compares the earliest and latest offsets of every
+// partition of the topics, with one pair of requests per leader, and returns
+// the topics holding records. Partitions without a leader fail their topic,
+// they may well hold records.
+func topicsHoldingRecords(broker *adminBroker, metadata *sarama.MetadataResponse, topics []string, failures map[string]error) []string {
+	byLeader := make(map[int32]map[string][]int32)
+	for _, topic := range topics {
+		partitions, _ := topicPartitions(metadata, topic)
+		if leaderless := leaderlessPartitions(metadata, partitions); len(leaderless) > 0 {
+			failures[topic] = fmt.Errorf("Partitions %v of topic %s have no leader, their records cannot be checked. Set force_destroy to delete it anyway", leaderless, topic)
+			continue
+		}
+
+		for leader, ids := range partitions {
+			if byLeader[leader] == nil {
+				byLeader[leader] = make(map[string][]int32)
+			}
+			byLeader[leader][topic] = ids
+		}
+	}
+
+	holding := make(map[string]bool)
+	for _, b := range metadata.Brokers {
+		led, ok := byLeader[b.ID()]
+		if !ok {
+			continue
+		}
+
+		names := make([]string, 0, len(led))
+		for topic := range led {
+			names = append(names, topic)
+		}
+		sort.Strings(names)
+
+		if err := openBroker(b); err != nil {
+			recordTopicsFailure(failures, names, err)
+			continue
+		}
+
+		earliest, err := broker.peer(b).GetAvailableOffsets(r.GetKafkaTopicsOffsetsRequest(led, sarama.OffsetOldest))
+		var latest *sarama.OffsetResponse
+		if err == nil {
+			latest, err = broker.peer(b).GetAvailableOffsets(r.GetKafkaTopicsOffsetsRequest(led, sarama.OffsetNewest))
+		}
+		b.Close()
+		if err != nil {
+			log.Println(err.Error())
+			recordTopicsFailure(failures, names, err)
+			continue
+		}
+
+		for _, topic := range names {
+			holdsRecords, err := partitionsHoldRecords(earliest, latest, topic, led[topic])
+			if err != nil {
+				failures[topic] = err
+				continue
+			}
+
+			if holdsRecords {
+				holding[topic] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(holding))
+	for topic := range holding {
+		result = append(result, topic)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func partitionsHoldRecords(earliest, latest *sarama.OffsetResponse, topic string, partitions []int32) (bool, error) {
+	for _, partition := range partitions {
+		first := earliest.GetBlock(topic, partition)
+		last := latest.GetBlock(topic, partition)
+		if first == nil || last == nil {
+			return false, fmt.Errorf("No offsets returned for partition %d of topic %s", partition, topic)
+		}
+
+		if first.Err != sarama.ErrNoError {
+			return false, first.Err
+		}
+
+		if last.Err != sarama.ErrNoError {
+			return false, last.Err
+		}
+
+		if last.Offset > first.Offset {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// topicsConsumerGroups lists, for each topic, the consumer groups having
+// active members assigned to it or offsets committed on it. Every broker is
+// asked once for the groups it coordinates.
+func topicsConsumerGroups(broker *adminBroker, metadata *sarama.MetadataResponse, topics []string, failures map[string]error) map[string][]string {
+	consumers := make(map[string][]string)
+	if len(topics) == 0 {
+		return consumers
+	}
+
+	partitions := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		_, partitions[topic] = topicPartitions(metadata, topic)
+	}
+
+	for _, b := range metadata.Brokers {
+		if err := openBroker(b); err != nil {
+			recordTopicsFailure(failures, topics, err)
+			return consumers
+		}
+
+		groups, err := brokerTopicsConsumerGroups(broker.peer(b), partitions)
+		b.Close()
+		if err != nil {
+			recordTopicsFailure(failures, topics, err)
+			return consumers
+		}
+
+		for topic, ids := range groups {
+			consumers[topic] = append(consumers[topic], ids...)
+		}
+	}
+
+	for topic := range consumers {
+		sort.Strings(consumers[topic])
+	}
+
+	return consumers
+}
+
+func brokerTopicsConsumerGroups(broker *adminBroker, partitions map[string][]int32) (map[string][]string, error) {
+	listed, err := broker.ListGroups(&sarama.ListGroupsRequest{})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	if listed.Err != sarama.ErrNoError {
+		return nil, listed.Err
+	}
+
+	if len(listed.Groups) == 0 {
+		return nil, nil
+	}
+
+	groups := make([]string, 0, len(listed.Groups))
+	for group := range listed.Groups {
+		groups = append(groups, group)
+	}
+
+	described, err := broker.DescribeGroups(&sarama.DescribeGroupsRequest{Groups: groups})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	consumers := make(map[string][]string)
+	for _, group := range described.Groups {
+		unassigned := make(map[string][]int32)
+		for topic, ids := range partitions {
+			consumes, err := groupConsumesTopic(group, topic)
+			if err != nil {
+				return nil, err
+			}
+
+			if consumes {
+				consumers[topic] = append(consumers[topic], group.GroupId)
+				continue
+			}
+
+			unassigned[topic] = ids
+		}
+
+		if len(unassigned) == 0 {
+			continue
+		}
+
+		offsets, err := broker.FetchOffset(r.GetKafkaGroupTopicsOffsetsRequest(group.GroupId, unassigned))
+		if err != nil {
+			log.Println(err.Error())
+			return nil, err
+		}
+
+		for topic, ids := range unassigned {
+			committed, err := groupCommittedOffsets(offsets, group.GroupId, topic, ids)
+			if err != nil {
+				return nil, err
+			}
+
+			if committed {
+				consumers[topic] = append(consumers[topic], group.GroupId)
+			}
+		}
+	}
+
+	return consumers, nil
+}
+
+// groupConsumesTopic reports whether an active member of the group is assigned
+// partitions of the topic. Groups which cannot be described fail the check,
+// they may well be reading the topic.
+func groupConsumesTopic(group *sarama.GroupDescription, topic string) (bool, error) {
+	if group.Err != sarama.ErrNoError {
+		return false, fmt.Errorf("Error describing consumer group %s: %s", group.GroupId, group.Err)
+	}
+
+	if group.ProtocolType != "consumer" {
+		return false, nil
+	}
+
+	for _, member := range group.Members {
+		assignment, err := member.GetMemberAssignment()
+		if err != nil {
+			return false, fmt.Errorf("Error decoding the assignment of member %s of consumer group %s: %s", member.MemberId, group.GroupId, err)
+		}
+
+		if assignment == nil {
+			continue
+		}
+
+		if _, ok := assignment.Topics[topic]; ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// groupCommittedOffsets reports whether the group committed an offset on any
+// partition of the topic. Offsets which cannot be fetched, for instance while
+// the coordinator is loading, fail the check.
+func groupCommittedOffsets(response *sarama.OffsetFetchResponse, group string, topic string, partitions []int32) (bool, error) {
+	if response.Err != sarama.ErrNoError {
+		return false, fmt.Errorf("Error fetching the offsets of consumer group %s: %s", group, response.Err)
+	}
+
+	for _, partition := range partitions {
+		block := response.GetBlock(topic, partition)
+		if block == nil {
+			return false, fmt.Errorf("No offset returned for partition %d of topic %s in consumer group %s", partition, topic, group)
+		}
+
+		if block.Err != sarama.ErrNoError {
+			return false, fmt.Errorf("Error fetching the offset of partition %d of topic %s in consumer group %s: %s", partition, topic, group, block.Err)
+		}
+
+		if block.Offset >= 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,235 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDeletionMode(t *testing.T) {
+	for _, mode := range deletionModes() {
+		_, errs := validateDeletionMode(mode, "deletion_mode")
+		assert.Empty(t, errs)
+	}
+
+	_, errs := validateDeletionMode("drop", "deletion_mode")
+	assert.Len(t, errs, 1)
+}
+
+func TestHandleDeletionDisabled(t *testing.T) {
+	err := handleDeletionDisabled(nil, "mytopic", deletionModeFail)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "delete.topic.enable=false")
+
+	err = handleDeletionDisabled(nil, "mytopic", deletionModeAbandon)
+	assert.Nil(t, err)
+}
+
+func TestTopicDeletionDisabledUnknownController(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(mock.Addr(), mock.BrokerID()).
+			SetController(2),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	_, err := topicDeletionDisabled(newAdminScheduler(1).broker(broker), "mytopic")
+	assert.EqualError(t, err, "Controller 2 not found in metadata, cannot check delete.topic.enable")
+}
+
+func TestRetireTopic(t *testing.T) {
+	mock, broker := newMockTopicBroker(t)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"IncrementalAlterConfigsRequest": sarama.NewMockIncrementalAlterConfigsResponse(t),
+	})
+
+	assert.Nil(t, retireTopic(broker, "mytopic"))
+
+	var request *sarama.IncrementalAlterConfigsRequest
+	for _, exchange := range mock.History() {
+		if altered, ok := exchange.Request.(*sarama.IncrementalAlterConfigsRequest); ok {
+			request = altered
+		}
+	}
+	if assert.NotNil(t, request) && assert.Len(t, request.Resources, 1) {
+		// the other overrides of the topic are not sent
+		entries := request.Resources[0].ConfigEntries
+		assert.Len(t, entries, 1)
+		assert.Equal(t, sarama.IncrementalAlterConfigsOperationSet, entries["retention.ms"].Operation)
+		assert.Equal(t, retiredTopicRetentionMs, *entries["retention.ms"].Value)
+	}
+}
+
+func TestTopicDeletionProtected(t *testing.T) {
+	meta := &providerMeta{deletionProtection: true}
+
+	d := schema.TestResourceDataRaw(t, kafkaSchema(), map[string]interface{}{"name": "mytopic"})
+	assert.True(t, topicDeletionProtected(d, meta))
+
+	d = schema.TestResourceDataRaw(t, kafkaSchema(), map[string]interface{}{
+		"name":                "mytopic",
+		"deletion_protection": false,
+	})
+	assert.False(t, topicDeletionProtected(d, meta))
+
+	meta.deletionProtection = false
+	d = schema.TestResourceDataRaw(t, kafkaSchema(), map[string]interface{}{
+		"name":                "mytopic",
+		"deletion_protection": true,
+	})
+	assert.True(t, topicDeletionProtected(d, meta))
+}
+
+func TestPartitionsHoldRecords(t *testing.T) {
+	topic := "mytopic"
+	earliest := &sarama.OffsetResponse{Version: 1}
+	latest := &sarama.OffsetResponse{Version: 1}
+	earliest.AddTopicPartition(topic, 0, 10)
+	latest.AddTopicPartition(topic, 0, 10)
+
+	holdsRecords, err := partitionsHoldRecords(earliest, latest, topic, []int32{0})
+	assert.Nil(t, err)
+	assert.False(t, holdsRecords)
+
+	latest.AddTopicPartition(topic, 0, 12)
+	holdsRecords, err = partitionsHoldRecords(earliest, latest, topic, []int32{0})
+	assert.Nil(t, err)
+	assert.True(t, holdsRecords)
+
+	latest.AddTopicPartition(topic, 0, 10)
+	_, err = partitionsHoldRecords(earliest, latest, topic, []int32{0, 1})
+	assert.NotNil(t, err)
+}
+
+func TestGroupCommittedOffsets(t *testing.T) {
+	topic := "mytopic"
+	response := &sarama.OffsetFetchResponse{}
+	response.AddBlock(topic, 0, &sarama.OffsetFetchResponseBlock{Offset: -1})
+	committed, err := groupCommittedOffsets(response, "mygroup", topic, []int32{0})
+	assert.Nil(t, err)
+	assert.False(t, committed)
+
+	response.AddBlock(topic, 1, &sarama.OffsetFetchResponseBlock{Offset: 42})
+	committed, err = groupCommittedOffsets(response, "mygroup", topic, []int32{0, 1})
+	assert.Nil(t, err)
+	assert.True(t, committed)
+
+	// offsets which cannot be fetched do not count as uncommitted
+	_, err = groupCommittedOffsets(response, "mygroup", topic, []int32{0, 2})
+	assert.EqualError(t, err, "No offset returned for partition 2 of topic mytopic in consumer group mygroup")
+
+	response.AddBlock(topic, 2, &sarama.OffsetFetchResponseBlock{Offset: -1, Err: sarama.ErrOffsetsLoadInProgress})
+	_, err = groupCommittedOffsets(response, "mygroup", topic, []int32{0, 2})
+	assert.Error(t, err)
+
+	response.Err = sarama.ErrNotCoordinatorForConsumer
+	_, err = groupCommittedOffsets(response, "mygroup", topic, []int32{0})
+	assert.Error(t, err)
+}
+
+func TestGroupConsumesTopic(t *testing.T) {
+	consumes := func(group *sarama.GroupDescription, topic string) bool {
+		consumed, err := groupConsumesTopic(group, topic)
+		assert.Nil(t, err)
+		return consumed
+	}
+
+	group := &sarama.GroupDescription{GroupId: "mygroup", ProtocolType: "consumer"}
+	assert.False(t, consumes(group, "mytopic"))
+
+	group.ProtocolType = "connect"
+	assert.False(t, consumes(group, "mytopic"))
+
+	group.ProtocolType = "consumer"
+	group.Members = map[string]*sarama.GroupMemberDescription{
+		"member-1": {MemberAssignment: memberAssignment("othertopic", 0)},
+		"member-2": {MemberAssignment: memberAssignment("mytopic", 0, 1)},
+	}
+	assert.True(t, consumes(group, "mytopic"))
+	assert.False(t, consumes(group, "unknown"))
+
+	// a group which cannot be described may be reading the topic
+	group.Err = sarama.ErrConsumerCoordinatorNotAvailable
+	_, err := groupConsumesTopic(group, "unknown")
+	assert.Error(t, err)
+}
+
+// memberAssignment encodes a consumer protocol assignment of one topic
+func memberAssignment(topic string, partitions ...int32) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, int16(0))
+	binary.Write(buf, binary.BigEndian, int32(1))
+	binary.Write(buf, binary.BigEndian, int16(len(topic)))
+	buf.WriteString(topic)
+	binary.Write(buf, binary.BigEndian, int32(len(partitions)))
+	for _, partition := range partitions {
+		binary.Write(buf, binary.BigEndian, partition)
+	}
+	binary.Write(buf, binary.BigEndian, int32(-1))
+
+	return buf.Bytes()
+}
+
+func TestTopicHoldsRecordsLeaderless(t *testing.T) {
+	metadata := &sarama.MetadataResponse{}
+	metadata.AddBroker("localhost:9092", 1)
+	metadata.AddTopicPartition("mytopic", 0, 1, []int32{1}, []int32{1}, nil, sarama.ErrNoError)
+	metadata.AddTopicPartition("mytopic", 1, -1, []int32{2}, nil, nil, sarama.ErrLeaderNotAvailable)
+	metadata.AddTopicPartition("mytopic", 2, 2, []int32{2}, []int32{2}, nil, sarama.ErrNoError)
+
+	byLeader, _ := topicPartitions(metadata, "mytopic")
+	assert.Equal(t, []int32{1, 2}, leaderlessPartitions(metadata, byLeader))
+
+	// fails before any offset request
+	_, err := topicHoldsRecords(nil, metadata, "mytopic")
+	assert.EqualError(t, err, "Partitions [1 2] of topic mytopic have no leader, their records cannot be checked. Set force_destroy to delete it anyway")
+}
+
+func TestEnsureTopicsUnused(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(mock.Addr(), mock.BrokerID()).
+			SetLeader("empty", 0, mock.BrokerID()).
+			SetLeader("full", 0, mock.BrokerID()).
+			SetLeader("full", 1, mock.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("empty", 0, sarama.OffsetOldest, 3).
+			SetOffset("empty", 0, sarama.OffsetNewest, 3).
+			SetOffset("full", 0, sarama.OffsetOldest, 0).
+			SetOffset("full", 0, sarama.OffsetNewest, 0).
+			SetOffset("full", 1, sarama.OffsetOldest, 0).
+			SetOffset("full", 1, sarama.OffsetNewest, 5),
+		"ListGroupsRequest": sarama.NewMockListGroupsResponse(t),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	failures := make(map[string]error)
+	unused := ensureTopicsUnused(newAdminScheduler(1).broker(broker), []string{"empty", "full"}, failures)
+	assert.Equal(t, []string{"empty"}, unused)
+	assert.EqualError(t, failures["full"], "Topic full still holds records, set force_destroy to delete it anyway")
+
+	// a single pass for both topics
+	counts := requestCounts(mock)
+	assert.Equal(t, 1, counts["*sarama.MetadataRequest"])
+	assert.Equal(t, 2, counts["*sarama.OffsetRequest"])
+	assert.Equal(t, 1, counts["*sarama.ListGroupsRequest"])
+}
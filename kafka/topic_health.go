@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"log"
+	"sort"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// partitionStatusSchema describes the computed partition_status blocks
+func partitionStatusSchema() *schema.Schema {
+	replicas := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: description,
+			Elem:        &schema.Schema{Type: schema.TypeInt},
+		}
+	}
+
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "Leader and replica state of every partition",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"partition": &schema.Schema{
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"leader": &schema.Schema{
+					Type:        schema.TypeInt,
+					Computed:    true,
+					Description: "Broker leading the partition, -1 when offline",
+				},
+				"replicas":         replicas("Brokers hosting a replica"),
+				"isr":              replicas("Replicas in sync with the leader"),
+				"offline_replicas": replicas("Replicas on offline log directories or brokers"),
+			},
+		},
+	}
+}
+
+// partitionOffline reports partitions without any leader
+func partitionOffline(partition *sarama.PartitionMetadata) bool {
+	return partition.Leader < 0 || partition.Err == sarama.ErrLeaderNotAvailable
+}
+
+// partitionUnderReplicated reports partitions with replicas out of sync
+func partitionUnderReplicated(partition *sarama.PartitionMetadata) bool {
+	return len(partition.Isr) < len(partition.Replicas)
+}
+
+// flattenPartitionStatus converts partition metadata into partition_status
+// blocks and counts the unhealthy partitions
+func flattenPartitionStatus(partitions []*sarama.PartitionMetadata) (blocks []interface{}, underReplicated int, offline int) {
+	sorted := make([]*sarama.PartitionMetadata, len(partitions))
+	copy(sorted, partitions)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	blocks = make([]interface{}, 0, len(sorted))
+	for _, partition := range sorted {
+		if partitionOffline(partition) {
+			offline++
+		}
+
+		if partitionUnderReplicated(partition) {
+			underReplicated++
+		}
+
+		blocks = append(blocks, map[string]interface{}{
+			"partition":        int(partition.ID),
+			"leader":           int(partition.Leader),
+			"replicas":         flattenBrokerIDs(partition.Replicas),
+			"isr":              flattenBrokerIDs(partition.Isr),
+			"offline_replicas": flattenBrokerIDs(partition.OfflineReplicas),
+		})
+	}
+
+	return blocks, underReplicated, offline
+}
+
+func flattenBrokerIDs(ids []int32) []interface{} {
+	flattened := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		flattened = append(flattened, int(id))
+	}
+
+	return flattened
+}
+
+// replicationFactorRange returns the lowest and highest replica count of the partitions
+func replicationFactorRange(partitions []*sarama.PartitionMetadata) (min int, max int) {
+	for i, partition := range partitions {
+		replicas := len(partition.Replicas)
+		if i == 0 || replicas < min {
+			min = replicas
+		}
+
+		if replicas > max {
+			max = replicas
+		}
+	}
+
+	return min, max
+}
+
+// unevenPartitions counts the partitions whose replica count differs from
+// the replication factor
+func unevenPartitions(partitions []*sarama.PartitionMetadata, replicationFactor int) (uneven int) {
+	for _, partition := range partitions {
+		if len(partition.Replicas) != replicationFactor {
+			uneven++
+		}
+	}
+
+	return uneven
+}
+
+// readReplicationFactor picks the replication factor stored in state. Topics
+// whose partitions have different replica counts, after a half finished
+// reassignment for instance, keep the current value while it is within range
+// so that the plan does not flap between partitions. The partitions off that
+// value are reported in uneven_partitions.
+func readReplicationFactor(topic string, current int, min int, max int) int {
+	if min == max {
+		return min
+	}
+
+	log.Printf(
+		"[WARN] Kafka: topic %s has a non uniform replication factor, partitions have between %d and %d replicas",
+		topic, min, max,
+	)
+
+	if current >= min && current <= max {
+		return current
+	}
+
+	return max
+}
@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlattenPartitionStatus(t *testing.T) {
+	partitions := []*sarama.PartitionMetadata{
+		{ID: 2, Leader: -1, Replicas: []int32{3, 1}, Isr: []int32{}, OfflineReplicas: []int32{3, 1}, Err: sarama.ErrLeaderNotAvailable},
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2}, Isr: []int32{1, 2}},
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3}, Isr: []int32{2}},
+	}
+
+	blocks, underReplicated, offline := flattenPartitionStatus(partitions)
+	assert.Equal(t, 2, underReplicated)
+	assert.Equal(t, 1, offline)
+	assert.Len(t, blocks, 3)
+
+	first := blocks[0].(map[string]interface{})
+	assert.Equal(t, 0, first["partition"])
+	assert.Equal(t, 1, first["leader"])
+	assert.Equal(t, []interface{}{1, 2}, first["isr"])
+
+	last := blocks[2].(map[string]interface{})
+	assert.Equal(t, -1, last["leader"])
+	assert.Equal(t, []interface{}{3, 1}, last["offline_replicas"])
+}
+
+func TestReplicationFactorRange(t *testing.T) {
+	min, max := replicationFactorRange([]*sarama.PartitionMetadata{
+		{ID: 0, Replicas: []int32{1, 2, 3}},
+		{ID: 1, Replicas: []int32{1, 2}},
+		{ID: 2, Replicas: []int32{1, 2, 3, 4}},
+	})
+	assert.Equal(t, 2, min)
+	assert.Equal(t, 4, max)
+
+	min, max = replicationFactorRange(nil)
+	assert.Equal(t, 0, min)
+	assert.Equal(t, 0, max)
+}
+
+func TestUnevenPartitions(t *testing.T) {
+	partitions := []*sarama.PartitionMetadata{
+		{ID: 0, Replicas: []int32{1, 2, 3}},
+		{ID: 1, Replicas: []int32{1, 2}},
+		{ID: 2, Replicas: []int32{1, 2, 3, 4}},
+	}
+
+	assert.Equal(t, 2, unevenPartitions(partitions, 3))
+	assert.Equal(t, 3, unevenPartitions(partitions, 1))
+	assert.Equal(t, 0, unevenPartitions(nil, 3))
+}
+
+func TestReadReplicationFactor(t *testing.T) {
+	assert.Equal(t, 3, readReplicationFactor("mytopic", 2, 3, 3))
+	assert.Equal(t, 3, readReplicationFactor("mytopic", 3, 2, 4))
+	assert.Equal(t, 4, readReplicationFactor("mytopic", 0, 2, 4))
+	assert.Equal(t, 4, readReplicationFactor("mytopic", 5, 2, 4))
+}
@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"log"
+
+	"github.com/IBM/sarama"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// topicID returns the ID of a topic, empty when the cluster does not return
+// topic IDs
+func topicID(metadata *sarama.TopicMetadata) string {
+	if metadata.Uuid == (sarama.Uuid{}) {
+		return ""
+	}
+
+	return metadata.Uuid.String()
+}
+
+// topicRecreated reports whether the topic behind a recorded ID was deleted
+// and created again. An empty ID is only unknown, not a new topic.
+func topicRecreated(recorded string, current string) bool {
+	return recorded != "" && current != "" && recorded != current
+}
+
+// readTopicID returns the topic_id to record. A topic recreated out of band
+// keeps the recorded ID, so that the next plan replaces the resource
+func readTopicID(name string, recorded string, current string) string {
+	if topicRecreated(recorded, current) {
+		log.Printf("[WARN] Topic %s was recreated out of band, its ID changed from %s to %s", name, recorded, current)
+		return recorded
+	}
+
+	return current
+}
+
+// customizeDiffTopicID plans the replacement of a topic whose ID no longer
+// matches the recorded one
+func customizeDiffTopicID(d *schema.ResourceDiff, meta *providerMeta) error {
+	recorded := d.Get("topic_id").(string)
+	if d.Id() == "" || d.HasChange("name") || recorded == "" {
+		return nil
+	}
+
+	name := d.Get("name").(string)
+	topics, err := meta.topics.lookup(meta.broker, []string{name})
+	if err != nil {
+		return err
+	}
+
+	topic, ok := topics[name]
+	if !ok {
+		return nil
+	}
+
+	current := topicID(topic.metadata)
+	if !topicRecreated(recorded, current) {
+		return nil
+	}
+
+	if err := d.SetNew("topic_id", current); err != nil {
+		return err
+	}
+
+	return d.ForceNew("topic_id")
+}
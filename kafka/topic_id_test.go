@@ -0,0 +1,32 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicID(t *testing.T) {
+	assert.Equal(t, "", topicID(&sarama.TopicMetadata{Name: "orders"}))
+
+	id := sarama.Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	assert.Equal(t, id.String(), topicID(&sarama.TopicMetadata{Name: "orders", Uuid: id}))
+}
+
+func TestReadTopicID(t *testing.T) {
+	assert.Equal(t, "a", readTopicID("orders", "", "a"))
+	assert.Equal(t, "a", readTopicID("orders", "a", "a"))
+	// clusters before 2.8
+	assert.Equal(t, "", readTopicID("orders", "", ""))
+	// recreated out of band, replaced on the next plan
+	assert.Equal(t, "a", readTopicID("orders", "a", "b"))
+}
+
+func TestTopicRecreated(t *testing.T) {
+	assert.True(t, topicRecreated("a", "b"))
+	assert.False(t, topicRecreated("a", "a"))
+	// not recorded yet, or unknown before 2.8
+	assert.False(t, topicRecreated("", "a"))
+	assert.False(t, topicRecreated("a", ""))
+}
@@ -0,0 +1,335 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/armgoja/terraform-provider-kafka-old/kafka/helper"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// migrationIdleTimeout bounds the wait for the next record while copying a partition
+const migrationIdleTimeout = 30 * time.Second
+
+// migrationCaughtUpDelay is how long a partition has to stay quiet, with the
+// consumer at the high-water mark, for the copy to be complete. Fetches of
+// records already written return right away.
+const migrationCaughtUpDelay = 5 * time.Second
+
+// migrationPollInterval is the delay between two checks of an idle copy
+const migrationPollInterval = time.Second
+
+// customizeDiffTopicRename plans a replacement on rename, Kafka has no way to
+// rename a topic. With migrate_data the rename is applied in place by
+// resourceKafkaTopicMigrate instead.
+func customizeDiffTopicRename(d *schema.ResourceDiff) error {
+	if d.Id() == "" || !d.HasChange("name") || d.Get("migrate_data").(bool) {
+		return nil
+	}
+
+	return d.ForceNew("name")
+}
+
+// customizeDiffMigratedTopic plans an update while the old topic of a
+// migration is left to delete
+func customizeDiffMigratedTopic(d *schema.ResourceDiff) error {
+	if d.Get("migrated_from").(string) == "" {
+		return nil
+	}
+
+	return d.SetNewComputed("migrated_from")
+}
+
+// retryMigratedTopicDeletion deletes the old topic of a migration whose
+// deletion failed after its records were copied
+func retryMigratedTopicDeletion(d *schema.ResourceData, meta *providerMeta) error {
+	oldName, _ := d.GetChange("migrated_from")
+	if oldName.(string) == "" {
+		return nil
+	}
+
+	log.Printf("[INFO] Kafka: deleting topic %s, migrated to %s", oldName, d.Id())
+	err := deleteTopic(meta.broker, oldName.(string), d.Get("deletion_mode").(string), d.Timeout(schema.TimeoutUpdate))
+	meta.topics.invalidate(oldName.(string))
+	if err != nil {
+		// keep the old name planned as computed for the next apply
+		d.Set("migrated_from", oldName.(string))
+		return fmt.Errorf("Error deleting topic %s, migrated to %s: %s", oldName, d.Id(), err)
+	}
+
+	d.Set("migrated_from", "")
+	return nil
+}
+
+// migrationTarget describes the renamed topic from the planned values. The
+// replica_assignment read back from the old topic only applies to it while
+// it matches the planned partitions and replication factor, the brokers
+// place the partitions otherwise.
+func migrationTarget(d *schema.ResourceData) (helper.Topic, error) {
+	topic, err := r.CreateResourceParams(d)
+	if err != nil {
+		return topic, err
+	}
+
+	blocks := d.Get("replica_assignment").([]interface{})
+	if err := validateReplicaAssignment(blocks, topic.Partitions, topic.ReplicationFactor); err != nil {
+		log.Printf("[DEBUG] Kafka: not reusing the replica assignment of %s: %s", topic.Name, err)
+		topic.ReplicaAssignment = nil
+	}
+
+	return topic, nil
+}
+
+// resourceKafkaTopicMigrate creates the renamed topic, copies the records of
+// the old topic into it and deletes the old topic
+func resourceKafkaTopicMigrate(d *schema.ResourceData, m interface{}) error {
+	meta := m.(*providerMeta)
+	broker := meta.broker
+
+	oldName, newName := d.GetChange("name")
+	timeout := d.Timeout(schema.TimeoutUpdate)
+
+	// the state keeps pointing at the old topic until it is deleted
+	d.Partial(true)
+
+	// The records move with the topic, its consumers would not
+	if !d.Get("force_destroy").(bool) {
+		metadata, err := broker.GetMetadata(r.GetKafkaTopicMetadataRequest(oldName.(string)))
+		if err != nil {
+			log.Println(err.Error())
+			return err
+		}
+
+		if err := ensureTopicNotConsumed(broker, metadata, oldName.(string)); err != nil {
+			return err
+		}
+	}
+
+	topic, err := migrationTarget(d)
+	if err != nil {
+		return err
+	}
+
+	err = createMigrationTarget(broker, topic, timeout)
+	meta.topics.invalidate(topic.Name)
+	if err != nil {
+		return err
+	}
+
+	err = copyTopicRecords(broker, meta.brokerList, oldName.(string), newName.(string))
+	if err != nil {
+		err = fmt.Errorf("Error migrating records of topic %s to %s: %s", oldName, newName, err)
+
+		// the old topic still holds every record, drop the partial copy so
+		// that the next apply starts over
+		rollbackErr := deleteTopic(broker, newName.(string), deletionModeFail, timeout)
+		meta.topics.invalidate(newName.(string))
+		if rollbackErr != nil {
+			return fmt.Errorf("%s. Deleting the partial copy failed as well, delete topic %s before applying again: %s", err, newName, rollbackErr)
+		}
+
+		return err
+	}
+
+	// The new topic holds every record and was created from the planned
+	// values, the state moves to it before the old topic is deleted
+	d.SetId(newName.(string))
+	// the new topic has its own ID, read again from the cluster
+	d.Set("topic_id", "")
+	d.Set("migrated_from", oldName.(string))
+	for _, key := range []string{"name", "partitions", "replication_factor", "replica_assignment", "config_entries", "topic_id", "migrated_from"} {
+		d.SetPartial(key)
+	}
+
+	// a failed deletion is retried on the next apply
+	deletionMode := d.Get("deletion_mode").(string)
+
+	err = deleteTopic(broker, oldName.(string), deletionMode, timeout)
+	meta.topics.invalidate(oldName.(string))
+	if err != nil {
+		return fmt.Errorf("Records of topic %s were migrated to %s but deleting %s failed, it is retried on the next apply: %s", oldName, newName, oldName, err)
+	}
+
+	d.Set("migrated_from", "")
+
+	// the ACLs follow the topic to its new name
+	err = applyTopicAcls(d, broker)
+	if err != nil {
+		return err
+	}
+
+	d.Partial(false)
+
+	return resourceKafkaTopicRead(d, m)
+}
+
+// createMigrationTarget creates the renamed topic. An empty topic left by an
+// interrupted migration is reused, one holding records is refused.
+func createMigrationTarget(broker *adminBroker, topic helper.Topic, timeout time.Duration) error {
+	metadata, err := broker.GetMetadata(r.GetKafkaTopicMetadataRequest(topic.Name))
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+
+	if topicDeleted(metadata, topic.Name) {
+		err = createTopic(broker, topic, timeout)
+	} else {
+		var holdsRecords bool
+		holdsRecords, err = topicHoldsRecords(broker, metadata, topic.Name)
+		if err == nil && holdsRecords {
+			err = fmt.Errorf("Topic %s already exists and holds records, delete it before migrating into it", topic.Name)
+		}
+
+		if err == nil {
+			log.Printf("[WARN] Kafka: migrating into the existing empty topic %s", topic.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return waitForTopicReady(broker, topic.Name, timeout)
+}
+
+// copyTopicRecords copies every record of a topic into another one, keeping
+// partition numbers when the target has enough partitions. The copy holds a
+// slot of the admin scheduler for its whole duration.
+func copyTopicRecords(broker *adminBroker, brokerList string, from string, to string) error {
+	config := brokerConfig()
+	// record headers need at least 0.11
+	config.Version = sarama.V0_11_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Partitioner = sarama.NewManualPartitioner
+
+	return broker.withClient([]string{brokerList}, config, func(client sarama.Client) error {
+		return copyClientRecords(client, from, to)
+	})
+}
+
+// copyClientRecords is copyTopicRecords over an open client
+func copyClientRecords(client sarama.Client, from string, to string) error {
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+	defer consumer.Close()
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+	defer producer.Close()
+
+	partitions, err := client.Partitions(from)
+	if err != nil {
+		return err
+	}
+
+	targets, err := client.Partitions(to)
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("Topic %s has no partitions", to)
+	}
+
+	ends := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		oldest, err := client.GetOffset(from, partition, sarama.OffsetOldest)
+		if err != nil {
+			return err
+		}
+
+		newest, err := client.GetOffset(from, partition, sarama.OffsetNewest)
+		if err != nil {
+			return err
+		}
+		ends[partition] = newest
+
+		if newest <= oldest {
+			continue
+		}
+
+		target := targets[int(partition)%len(targets)]
+		log.Printf("[DEBUG] Kafka: copying offsets %d to %d of %s/%d into %s/%d", oldest, newest, from, partition, to, target)
+
+		partitionConsumer, err := consumer.ConsumePartition(from, partition, oldest)
+		if err != nil {
+			return err
+		}
+
+		err = copyPartitionRecords(partitionConsumer, producer, to, target, newest)
+		partitionConsumer.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// records produced since the copy started would be lost with the old topic
+	for _, partition := range partitions {
+		newest, err := client.GetOffset(from, partition, sarama.OffsetNewest)
+		if err != nil {
+			return err
+		}
+
+		if newest != ends[partition] {
+			return fmt.Errorf("Records were produced to %s/%d during the migration, stop its producers and apply again", from, partition)
+		}
+	}
+
+	return nil
+}
+
+// copyPartitionRecords produces the consumed records into the target partition
+// until the consumer has caught up with end. Transaction markers and
+// compacted records leave gaps, so the offset preceding end may never be
+// delivered: once the high-water mark reported to the consumer has reached
+// end and no record arrived for migrationCaughtUpDelay, the copy is complete.
+func copyPartitionRecords(consumer sarama.PartitionConsumer, producer sarama.SyncProducer, topic string, partition int32, end int64) error {
+	ticker := time.NewTicker(migrationPollInterval)
+	defer ticker.Stop()
+
+	lastRecord := time.Now()
+	for {
+		select {
+		case message := <-consumer.Messages():
+			headers := make([]sarama.RecordHeader, 0, len(message.Headers))
+			for _, header := range message.Headers {
+				headers = append(headers, *header)
+			}
+
+			_, _, err := producer.SendMessage(&sarama.ProducerMessage{
+				Topic:     topic,
+				Partition: partition,
+				Key:       sarama.ByteEncoder(message.Key),
+				Value:     sarama.ByteEncoder(message.Value),
+				Headers:   headers,
+				Timestamp: message.Timestamp,
+			})
+			if err != nil {
+				return err
+			}
+
+			if message.Offset+1 >= end {
+				return nil
+			}
+			lastRecord = time.Now()
+		case <-ticker.C:
+			idle := time.Since(lastRecord)
+			if idle >= migrationCaughtUpDelay && consumer.HighWaterMarkOffset() >= end {
+				return nil
+			}
+
+			if idle >= migrationIdleTimeout {
+				return fmt.Errorf("no record received for %s while copying into %s/%d", migrationIdleTimeout, topic, partition)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationTarget(t *testing.T) {
+	raw := map[string]interface{}{
+		"name":               "orders-v2",
+		"partitions":         3,
+		"replication_factor": 2,
+		"replica_assignment": []interface{}{
+			map[string]interface{}{"partition": 0, "replicas": []interface{}{1, 2}},
+			map[string]interface{}{"partition": 1, "replicas": []interface{}{2, 3}},
+		},
+	}
+
+	// the assignment of the old topic misses the added partition
+	topic, err := migrationTarget(schema.TestResourceDataRaw(t, kafkaSchema(), raw))
+	assert.Nil(t, err)
+	assert.Equal(t, "orders-v2", topic.Name)
+	assert.Equal(t, 3, topic.Partitions)
+	assert.Equal(t, 2, topic.ReplicationFactor)
+	assert.Empty(t, topic.ReplicaAssignment)
+
+	raw["replica_assignment"] = append(raw["replica_assignment"].([]interface{}),
+		map[string]interface{}{"partition": 2, "replicas": []interface{}{3, 1}})
+	topic, err = migrationTarget(schema.TestResourceDataRaw(t, kafkaSchema(), raw))
+	assert.Nil(t, err)
+	assert.Equal(t, map[int32][]int32{0: {1, 2}, 1: {2, 3}, 2: {3, 1}}, topic.ReplicaAssignment)
+}
+
+func TestCreateMigrationTargetHoldingRecords(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(mock.Addr(), mock.BrokerID()).
+			SetLeader("mytopic", 0, mock.BrokerID()).
+			SetLeader("mytopic", 1, mock.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("mytopic", 0, sarama.OffsetOldest, 0).
+			SetOffset("mytopic", 0, sarama.OffsetNewest, 4).
+			SetOffset("mytopic", 1, sarama.OffsetOldest, 0).
+			SetOffset("mytopic", 1, sarama.OffsetNewest, 0),
+	})
+
+	b := sarama.NewBroker(mock.Addr())
+	if err := openBroker(b); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	broker := newAdminScheduler(1).broker(b)
+
+	topic, err := migrationTarget(schema.TestResourceDataRaw(t, kafkaSchema(), map[string]interface{}{"name": "mytopic"}))
+	assert.Nil(t, err)
+
+	err = createMigrationTarget(broker, topic, time.Second)
+	assert.EqualError(t, err, "Topic mytopic already exists and holds records, delete it before migrating into it")
+	assert.Zero(t, requestCounts(mock)["*sarama.CreateTopicsRequest"])
+}
+
+func TestCopyPartitionRecords(t *testing.T) {
+	consumer := mocks.NewConsumer(t, nil)
+	defer consumer.Close()
+	consumer.ExpectConsumePartition("orders", 0, sarama.OffsetOldest).
+		YieldMessage(&sarama.ConsumerMessage{Key: []byte("a"), Value: []byte("1")}).
+		YieldMessage(&sarama.ConsumerMessage{
+			Key:     []byte("b"),
+			Value:   []byte("2"),
+			Headers: []*sarama.RecordHeader{{Key: []byte("source"), Value: []byte("orders")}},
+		})
+
+	var produced []*sarama.ProducerMessage
+	record := func(message *sarama.ProducerMessage) error {
+		produced = append(produced, message)
+		return nil
+	}
+	// the partition is chosen by copyPartitionRecords, as with copyTopicRecords
+	config := mocks.NewTestConfig()
+	config.Producer.Partitioner = sarama.NewManualPartitioner
+	producer := mocks.NewSyncProducer(t, config)
+	defer producer.Close()
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(record)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(record)
+
+	partitionConsumer, err := consumer.ConsumePartition("orders", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, copyPartitionRecords(partitionConsumer, producer, "orders-v2", 1, 2))
+	if assert.Len(t, produced, 2) {
+		assert.Equal(t, "orders-v2", produced[1].Topic)
+		assert.Equal(t, int32(1), produced[1].Partition)
+		assert.Equal(t, sarama.ByteEncoder("b"), produced[1].Key)
+		assert.Equal(t, []sarama.RecordHeader{{Key: []byte("source"), Value: []byte("orders")}}, produced[1].Headers)
+	}
+}
+
+func TestCopyPartitionRecordsProduceError(t *testing.T) {
+	consumer := mocks.NewConsumer(t, nil)
+	defer consumer.Close()
+	consumer.ExpectConsumePartition("orders", 0, sarama.OffsetOldest).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("1")})
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageAndFail(errors.New("not enough replicas"))
+
+	partitionConsumer, err := consumer.ConsumePartition("orders", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.EqualError(t, copyPartitionRecords(partitionConsumer, producer, "orders-v2", 0, 1), "not enough replicas")
+}
+
+func TestRetryMigratedTopicDeletion(t *testing.T) {
+	mock := sarama.NewMockBroker(t, 1)
+	defer mock.Close()
+	mock.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(mock.Addr(), mock.BrokerID()).
+			SetController(2),
+	})
+
+	broker := sarama.NewBroker(mock.Addr())
+	if err := openBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	meta := &providerMeta{broker: newAdminScheduler(1).broker(broker), topics: newTopicCache()}
+	state := &terraform.InstanceState{
+		ID: "orders-v2",
+		Attributes: map[string]string{
+			"name":          "orders-v2",
+			"deletion_mode": deletionModeFail,
+		},
+	}
+
+	// nothing left to delete
+	d := resourceKafkaTopic().Data(state)
+	assert.Nil(t, retryMigratedTopicDeletion(d, meta))
+	assert.Empty(t, mock.History())
+
+	// a failed deletion stays planned for the next apply
+	state.Attributes["migrated_from"] = "orders"
+	d = resourceKafkaTopic().Data(state)
+	err := retryMigratedTopicDeletion(d, meta)
+	assert.EqualError(t, err, "Error deleting topic orders, migrated to orders-v2: Controller 2 not found in metadata, cannot check delete.topic.enable")
+	assert.Equal(t, "orders", d.State().Attributes["migrated_from"])
+}
@@ -0,0 +1,99 @@
+package kafka
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// maxTopicNameLength is the longest topic name accepted by the brokers
+const maxTopicNameLength = 249
+
+var legalTopicName = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// validateTopicName applies the broker side topic name rules at plan time
+func validateTopicName(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+
+	switch {
+	case value == "":
+		errors = append(errors, fmt.Errorf("%s must not be an empty string", k))
+	case value == "." || value == "..":
+		errors = append(errors, fmt.Errorf("%s cannot be %q", k, value))
+	case len(value) > maxTopicNameLength:
+		errors = append(errors, fmt.Errorf("%s must not be longer than %d characters, got %d", k, maxTopicNameLength, len(value)))
+	case !legalTopicName.MatchString(value):
+		errors = append(errors, fmt.Errorf("%s %q may only contain ASCII alphanumerics, '.', '_' and '-'", k, value))
+	case strings.Contains(value, ".") && strings.Contains(value, "_"):
+		ws = append(ws, fmt.Sprintf("%s %q contains both '.' and '_' which collide in metric names, use one of them only", k, value))
+	}
+
+	return
+}
+
+// validateTopicNamePattern checks the provider topic_name_pattern is a valid regexp
+func validateTopicNamePattern(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := compileTopicNamePattern(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%s is not a valid regular expression: %s", k, err))
+	}
+
+	return
+}
+
+// compileTopicNamePattern anchors the pattern so that it matches whole topic names
+func compileTopicNamePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// topicNameCollision returns the existing topic whose metric names would
+// collide with the given one, a.b and a_b share the same metric names. The
+// current name of a renamed topic goes away with the rename, it is skipped.
+func topicNameCollision(topic string, existing []string, renamed string) string {
+	normalized := strings.Replace(topic, ".", "_", -1)
+	for _, name := range existing {
+		if name == renamed {
+			continue
+		}
+
+		if name != topic && strings.Replace(name, ".", "_", -1) == normalized {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// customizeDiffTopicName enforces the provider naming convention and checks
+// new topic names against the topics of the cluster
+func customizeDiffTopicName(d *schema.ResourceDiff, meta *providerMeta) error {
+	if d.Id() != "" && !d.HasChange("name") {
+		return nil
+	}
+
+	name := d.Get("name").(string)
+	if name == "" {
+		return nil
+	}
+
+	if meta.topicNamePattern != nil && !meta.topicNamePattern.MatchString(name) {
+		return fmt.Errorf("Topic name %q does not match the provider topic_name_pattern %s", name, meta.topicNamePattern)
+	}
+
+	existing, err := meta.topics.names(meta.broker)
+	if err != nil {
+		return err
+	}
+
+	// the ID of an existing kafka_topic is its current name
+	if collision := topicNameCollision(name, existing, d.Id()); collision != "" {
+		return fmt.Errorf("Topic name %q collides with existing topic %q, '.' and '_' share the same metric names", name, collision)
+	}
+
+	return nil
+}
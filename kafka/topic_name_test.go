@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTopicName(t *testing.T) {
+	for _, name := range []string{"orders", "sales.orders.v1", "sales-orders_v1"} {
+		ws, errs := validateTopicName(name, "name")
+		assert.Empty(t, ws)
+		assert.Empty(t, errs)
+	}
+
+	for _, name := range []string{"", ".", "..", "orders/v1", "orders v1", strings.Repeat("a", 250)} {
+		_, errs := validateTopicName(name, "name")
+		assert.Len(t, errs, 1, name)
+	}
+
+	ws, errs := validateTopicName("sales.orders_v1", "name")
+	assert.Len(t, ws, 1)
+	assert.Empty(t, errs)
+}
+
+func TestCompileTopicNamePattern(t *testing.T) {
+	pattern, err := compileTopicNamePattern("")
+	assert.Nil(t, err)
+	assert.Nil(t, pattern)
+
+	pattern, err = compileTopicNamePattern(`[a-z]+\.[a-z]+\.v[0-9]+`)
+	assert.Nil(t, err)
+	assert.True(t, pattern.MatchString("sales.orders.v1"))
+	assert.False(t, pattern.MatchString("sales.orders"))
+	assert.False(t, pattern.MatchString("x.sales.orders.v1.y"))
+
+	_, errs := validateTopicNamePattern("[a-z", "topic_name_pattern")
+	assert.Len(t, errs, 1)
+}
+
+func TestTopicNameCollision(t *testing.T) {
+	existing := []string{"sales.orders", "payments"}
+	assert.Equal(t, "sales.orders", topicNameCollision("sales_orders", existing, ""))
+	assert.Equal(t, "", topicNameCollision("sales.orders", existing, ""))
+	assert.Equal(t, "", topicNameCollision("sales.refunds", existing, ""))
+
+	// renaming sales.orders to sales_orders
+	assert.Equal(t, "", topicNameCollision("sales_orders", existing, "sales.orders"))
+	assert.Equal(t, "sales.orders", topicNameCollision("sales_orders", existing, "payments"))
+}
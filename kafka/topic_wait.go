@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// topicPollInterval is the delay between two metadata checks while waiting on a topic
+const topicPollInterval = 2 * time.Second
+
+// waitForTopic polls the cluster until check reports true or the timeout expires
+func waitForTopic(topic string, timeout time.Duration, check func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout after %s waiting for topic %s", timeout, topic)
+		}
+
+		log.Printf("[DEBUG] Kafka: topic %s not settled yet, retrying in %s", topic, topicPollInterval)
+		time.Sleep(topicPollInterval)
+	}
+}
+
+// waitForTopicReady waits until every broker of the cluster reports the topic
+// with a leader and a full ISR for all of its partitions
+func waitForTopicReady(broker *adminBroker, topic string, timeout time.Duration) error {
+	return waitForTopicsReady(broker, []string{topic}, timeout)
+}
+
+// waitForTopicsReady is waitForTopicReady for several topics at once
+func waitForTopicsReady(broker *adminBroker, topics []string, timeout time.Duration) error {
+	return waitForTopic(strings.Join(topics, ", "), timeout, func() (bool, error) {
+		return topicsReadyOnAllBrokers(broker, topics)
+	})
+}
+
+func topicsReadyOnAllBrokers(broker *adminBroker, topics []string) (bool, error) {
+	request := r.GetKafkaTopicsMetadataRequest(topics)
+
+	response, err := broker.GetMetadata(request)
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
+	}
+
+	if !topicsReady(response, topics) {
+		return false, nil
+	}
+
+	for _, b := range response.Brokers {
+		if err := openBroker(b); err != nil {
+			return false, err
+		}
+
+		brokerResponse, err := broker.peer(b).GetMetadata(request)
+		b.Close()
+		if err != nil {
+			log.Printf("Error retrieving metadata from broker %s: %s", b.Addr(), err.Error())
+			return false, err
+		}
+
+		if !topicsReady(brokerResponse, topics) {
+			log.Printf("[DEBUG] Kafka: topics not yet propagated to broker %d", b.ID())
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func topicsReady(response *sarama.MetadataResponse, topics []string) bool {
+	for _, topic := range topics {
+		if !topicReady(response, topic) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// topicReady reports whether the metadata describes the topic with a leader
+// and a full ISR for every partition
+func topicReady(response *sarama.MetadataResponse, topic string) bool {
+	for _, t := range response.Topics {
+		if t.Name != topic {
+			continue
+		}
+
+		if t.Err != sarama.ErrNoError || len(t.Partitions) == 0 {
+			return false
+		}
+
+		for _, partition := range t.Partitions {
+			if partition.Err != sarama.ErrNoError || partition.Leader < 0 {
+				return false
+			}
+
+			if len(partition.Isr) < len(partition.Replicas) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// waitForTopicDeleted waits until the topic has disappeared from the cluster metadata
+func waitForTopicDeleted(broker *adminBroker, topic string, timeout time.Duration) error {
+	return waitForTopicsDeleted(broker, []string{topic}, timeout)
+}
+
+// waitForTopicsDeleted is waitForTopicDeleted for several topics at once
+func waitForTopicsDeleted(broker *adminBroker, topics []string, timeout time.Duration) error {
+	return waitForTopic(strings.Join(topics, ", "), timeout, func() (bool, error) {
+		response, err := broker.GetMetadata(r.GetKafkaTopicsMetadataRequest(topics))
+		if err != nil {
+			log.Println(err.Error())
+			return false, err
+		}
+
+		for _, topic := range topics {
+			if !topicDeleted(response, topic) {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// topicDeleted reports whether the metadata no longer knows about the topic
+func topicDeleted(response *sarama.MetadataResponse, topic string) bool {
+	for _, t := range response.Topics {
+		if t.Name == topic && t.Err != sarama.ErrUnknownTopicOrPartition {
+			return false
+		}
+	}
+
+	return true
+}
+
+// topicPendingDeletion reports whether a CreateTopics error was caused by a
+// previous deletion of the same topic which has not completed yet
+func topicPendingDeletion(topicErr *sarama.TopicError) bool {
+	if topicErr == nil || topicErr.Err != sarama.ErrTopicAlreadyExists || topicErr.ErrMsg == nil {
+		return false
+	}
+
+	return strings.Contains(*topicErr.ErrMsg, "marked for deletion")
+}
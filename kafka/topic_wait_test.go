@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicReady(t *testing.T) {
+	topic := "mytopic"
+	response := &sarama.MetadataResponse{
+		Topics: []*sarama.TopicMetadata{
+			{
+				Name: topic,
+				Err:  sarama.ErrNoError,
+				Partitions: []*sarama.PartitionMetadata{
+					{ID: 0, Leader: 1, Replicas: []int32{1, 2}, Isr: []int32{1, 2}},
+					{ID: 1, Leader: 2, Replicas: []int32{2, 1}, Isr: []int32{2, 1}},
+				},
+			},
+		},
+	}
+	assert.True(t, topicReady(response, topic))
+	assert.False(t, topicReady(response, "othertopic"))
+
+	response.Topics[0].Partitions[1].Isr = []int32{2}
+	assert.False(t, topicReady(response, topic))
+
+	response.Topics[0].Partitions[1].Isr = []int32{2, 1}
+	response.Topics[0].Partitions[0].Err = sarama.ErrLeaderNotAvailable
+	assert.False(t, topicReady(response, topic))
+
+	response.Topics[0].Partitions = nil
+	assert.False(t, topicReady(response, topic))
+
+	response.Topics[0].Err = sarama.ErrUnknownTopicOrPartition
+	assert.False(t, topicReady(response, topic))
+}
+
+func TestTopicDeleted(t *testing.T) {
+	topic := "mytopic"
+	response := &sarama.MetadataResponse{
+		Topics: []*sarama.TopicMetadata{
+			{Name: topic, Err: sarama.ErrNoError},
+		},
+	}
+	assert.False(t, topicDeleted(response, topic))
+	assert.True(t, topicDeleted(response, "othertopic"))
+
+	response.Topics[0].Err = sarama.ErrUnknownTopicOrPartition
+	assert.True(t, topicDeleted(response, topic))
+}
+
+func TestTopicPendingDeletion(t *testing.T) {
+	msg := "Topic 'mytopic' is marked for deletion."
+	assert.False(t, topicPendingDeletion(nil))
+	assert.False(t, topicPendingDeletion(&sarama.TopicError{Err: sarama.ErrNoError}))
+	assert.False(t, topicPendingDeletion(&sarama.TopicError{Err: sarama.ErrTopicAlreadyExists}))
+	assert.False(t, topicPendingDeletion(&sarama.TopicError{Err: sarama.ErrInvalidPartitions, ErrMsg: &msg}))
+	assert.True(t, topicPendingDeletion(&sarama.TopicError{Err: sarama.ErrTopicAlreadyExists, ErrMsg: &msg}))
+}